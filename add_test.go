@@ -57,6 +57,7 @@ func testadd(t *testing.T, data []addtestdata) {
 			out,
 			errout,
 			d.xpath,
+			nil,
 			d.name,
 			d.value,
 			d.ennet,
@@ -140,7 +141,7 @@ func TestAdd(t *testing.T) {
 			xpath: `/root/hoge`,
 			name:  `@attr1`,
 			value: `attrvalue"1'`,
-			out:   xmlpi + `<root><hoge attr1="attrvalue&#34;1&#39;"/></root>`,
+			out:   xmlpi + `<root><hoge attr1="attrvalue&quot;1'"/></root>`,
 		},
 		{ /*text*/
 			input: xmlpi + `<root><hoge/></root>`,
@@ -247,6 +248,7 @@ func BenchmarkAdd(b *testing.B) {
 			out,
 			errout,
 			`/root/hoge`,
+			nil,
 			``,
 			``,
 			`a>b`,
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// C14N modes of OutputConfig
+const (
+	C14NInclusive = "inclusive"
+	C14NExclusive = "exclusive"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// ParseC14N parses --c14n, "inclusive" or "exclusive" optionally followed by ",with-comments".
+func ParseC14N(s string) (mode string, comments bool, err error) {
+	if s == "" {
+		return "", false, nil
+	}
+
+	mode = C14NInclusive
+	for _, word := range strings.Split(s, ",") {
+		switch strings.TrimSpace(word) {
+		case C14NInclusive, "1.0":
+			mode = C14NInclusive
+		case C14NExclusive:
+			mode = C14NExclusive
+		case "with-comments":
+			comments = true
+		default:
+			return "", false, fmt.Errorf("c14n: unknown mode %q", word)
+		}
+	}
+	return mode, comments, nil
+}
+
+// c14nWriter writes Canonical XML 1.0 or Exclusive XML Canonicalization 1.0.
+type c14nWriter struct {
+	b         *bufio.Writer
+	exclusive bool
+	comments  bool
+}
+
+type c14nNS struct {
+	prefix, uri string
+}
+
+func writeC14N(b *bufio.Writer, n *xmlquery.Node, config OutputConfig) {
+	w := &c14nWriter{b: b, exclusive: config.C14N == C14NExclusive, comments: config.C14NComments}
+
+	if n.Type != xmlquery.DocumentNode {
+		w.node(n, nil)
+		return
+	}
+
+	nodes := prologNodes(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+
+	// outside the document element, a line feed separates nodes from it
+	afterRoot := false
+	for _, c := range nodes {
+		switch {
+		case c.Type == xmlquery.ElementNode:
+			w.node(c, nil)
+			afterRoot = true
+		case c.Type == xmlquery.CommentNode && w.comments,
+			c.Type == xmlquery.DeclarationNode && c.Data != "xml":
+			if afterRoot {
+				b.WriteByte('\n')
+			}
+			w.node(c, nil)
+			if !afterRoot {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// node writes n. rendered is namespace declarations in effect in the output ancestors.
+func (w *c14nWriter) node(n *xmlquery.Node, rendered map[string]string) {
+	switch n.Type {
+	case xmlquery.ElementNode:
+		w.element(n, rendered)
+
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		w.b.WriteString(strings.NewReplacer(
+			"&", "&amp;",
+			"<", "&lt;",
+			">", "&gt;",
+			"\r", "&#xD;",
+		).Replace(n.Data))
+
+	case xmlquery.CommentNode:
+		if w.comments {
+			w.b.WriteString("<!--" + n.Data + "-->")
+		}
+
+	case xmlquery.DeclarationNode:
+		if n.Data == "xml" {
+			return
+		}
+		w.b.WriteString("<?" + n.Data)
+		writePIAttrs(w.b, n.Attr)
+		w.b.WriteString("?>")
+	}
+}
+
+func (w *c14nWriter) element(n *xmlquery.Node, rendered map[string]string) {
+	// namespaces in scope, from n to the top
+	inScope := make(map[string]string)
+	for e := n; e != nil; e = e.Parent {
+		for _, attr := range e.Attr {
+			prefix, ok := "", false
+			switch {
+			case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				ok = true
+			case attr.Name.Space == "xmlns":
+				prefix, ok = attr.Name.Local, true
+			}
+			if _, found := inScope[prefix]; ok && !found {
+				inScope[prefix] = attr.Value
+			}
+		}
+	}
+
+	var attrs []xmlquery.Attr
+	for _, attr := range n.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+
+	// namespaces to render
+	var prefixes []string
+	if w.exclusive {
+		// visibly utilized ones
+		prefixes = append(prefixes, n.Prefix)
+		for _, attr := range attrs {
+			if attr.Name.Space != "" {
+				prefixes = append(prefixes, attr.Name.Space)
+			}
+		}
+	} else {
+		prefixes = slices.Collect(maps.Keys(inScope))
+	}
+
+	var decls []c14nNS
+	for _, prefix := range prefixes {
+		uri := inScope[prefix]
+		switch {
+		case prefix == "xml", rendered[prefix] == uri:
+			continue
+		case prefix != "" && uri == "":
+			// not bound
+			continue
+		case slices.Contains(decls, c14nNS{prefix, uri}):
+			continue
+		}
+		decls = append(decls, c14nNS{prefix, uri})
+	}
+
+	next := rendered
+	if len(decls) > 0 {
+		next = make(map[string]string, len(rendered)+len(decls))
+		maps.Copy(next, rendered)
+		for _, d := range decls {
+			next[d.prefix] = d.uri
+		}
+	}
+	slices.SortFunc(decls, func(a, b c14nNS) int {
+		return cmp.Compare(a.prefix, b.prefix)
+	})
+
+	// attributes in the order of namespace URI and local name, unqualified ones first
+	attrURI := func(attr xmlquery.Attr) string {
+		switch attr.Name.Space {
+		case "":
+			return ""
+		case "xml":
+			return xmlNamespace
+		}
+		return inScope[attr.Name.Space]
+	}
+	slices.SortStableFunc(attrs, func(a, b xmlquery.Attr) int {
+		return cmp.Or(cmp.Compare(attrURI(a), attrURI(b)), cmp.Compare(a.Name.Local, b.Name.Local))
+	})
+
+	w.b.WriteByte('<')
+	writeName(w.b, n.Prefix, n.Data)
+	for _, d := range decls {
+		if d.prefix == "" {
+			w.b.WriteString(" xmlns=\"")
+		} else {
+			w.b.WriteString(" xmlns:" + d.prefix + "=\"")
+		}
+		w.b.WriteString(escapeC14NAttr(d.uri))
+		w.b.WriteByte('"')
+	}
+	for _, attr := range attrs {
+		w.b.WriteByte(' ')
+		writeName(w.b, attr.Name.Space, attr.Name.Local)
+		w.b.WriteString("=\"")
+		w.b.WriteString(escapeC14NAttr(attr.Value))
+		w.b.WriteByte('"')
+	}
+	w.b.WriteByte('>')
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c, next)
+	}
+
+	w.b.WriteString("</")
+	writeName(w.b, n.Prefix, n.Data)
+	w.b.WriteByte('>')
+}
+
+func escapeC14NAttr(s string) string {
+	return strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		`"`, "&quot;",
+		"\t", "&#x9;",
+		"\n", "&#xA;",
+		"\r", "&#xD;",
+	).Replace(s)
+}
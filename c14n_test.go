@@ -0,0 +1,89 @@
+package main_test
+
+import (
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+const c14nsrc = `<?xml version="1.0"?>
+<?pi a="1"?>
+<!DOCTYPE doc>
+<!-- before -->
+<doc xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b">
+  <e1 />
+  <e2 b:x="4" z="3" a:y="2" y='1' xmlns:c="urn:c">A &amp; B &gt; "C"<![CDATA[<x>]]><!-- in --></e2>
+  <a:e3 v="t&#9;a&#10;b" xmlns=""><e4 xmlns="urn:d"/></a:e3>
+</doc>
+<!-- after -->`
+
+func TestC14N(t *testing.T) {
+	data := []struct {
+		c14n string
+		out  string
+	}{
+		{
+			c14n: "inclusive",
+			out: `<?pi a="1"?>
+<doc xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b">
+  <e1></e1>
+  <e2 xmlns:c="urn:c" y="1" z="3" a:y="2" b:x="4">A &amp; B &gt; "C"&lt;x&gt;</e2>
+  <a:e3 xmlns="" v="t&#x9;a&#xA;b"><e4 xmlns="urn:d"></e4></a:e3>
+</doc>`,
+		},
+		{
+			c14n: "inclusive,with-comments",
+			out: `<?pi a="1"?>
+<!-- before -->
+<doc xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b">
+  <e1></e1>
+  <e2 xmlns:c="urn:c" y="1" z="3" a:y="2" b:x="4">A &amp; B &gt; "C"&lt;x&gt;<!-- in --></e2>
+  <a:e3 xmlns="" v="t&#x9;a&#xA;b"><e4 xmlns="urn:d"></e4></a:e3>
+</doc>
+<!-- after -->`,
+		},
+		{
+			c14n: "exclusive",
+			out: `<?pi a="1"?>
+<doc xmlns="urn:d">
+  <e1></e1>
+  <e2 xmlns:a="urn:a" xmlns:b="urn:b" y="1" z="3" a:y="2" b:x="4">A &amp; B &gt; "C"&lt;x&gt;</e2>
+  <a:e3 xmlns:a="urn:a" v="t&#x9;a&#xA;b"><e4></e4></a:e3>
+</doc>`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		mode, comments, err := main.ParseC14N(d.c14n)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+
+		in, out, errout := prepare(c14nsrc)
+		err = main.Delete(main.NewFakeCloseReader(in), out, errout, `//nothing`, nil, main.OutputConfig{Indent: "    ", C14N: mode, C14NComments: comments})
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+	}
+
+	_, _, err := main.ParseC14N("exclusive,nocomments")
+	gotwant.Test(t, err.Error(), `c14n: unknown mode "nocomments"`)
+}
+
+func TestC14NSubtree(t *testing.T) {
+	ns := map[string]string{"a": "urn:a"}
+
+	for _, d := range []struct {
+		c14n string
+		out  string
+	}{
+		{c14n: main.C14NInclusive, out: `<a:e3 xmlns:a="urn:a" xmlns:b="urn:b" v="t&#x9;a&#xA;b"><e4 xmlns="urn:d"></e4></a:e3>`},
+		{c14n: main.C14NExclusive, out: `<a:e3 xmlns:a="urn:a" v="t&#x9;a&#xA;b"><e4 xmlns="urn:d"></e4></a:e3>`},
+	} {
+		in, out, errout := prepare(c14nsrc)
+		err := main.Get(main.NewFakeCloseReader(in), out, errout, `//a:e3`, ns, false, "\n", "outer-xml", nil, false, main.OutputConfig{C14N: d.c14n})
+		gotwant.TestError(t, err, nil, gotwant.Desc(d.c14n))
+		gotwant.Test(t, readAll(out), d.out+"\n", gotwant.Desc(d.c14n))
+	}
+}
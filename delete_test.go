@@ -12,6 +12,7 @@ import (
 type deletetestdata struct {
 	input string
 	xpath string
+	ns    map[string]string
 
 	indent int
 
@@ -29,6 +30,7 @@ func testdelete(t *testing.T, data []deletetestdata) {
 			out,
 			errout,
 			d.xpath,
+			d.ns,
 			main.OutputConfig{Indent: strings.Repeat(" ", d.indent), EmptyElement: true},
 		)
 
@@ -52,6 +54,11 @@ func TestDelete(t *testing.T) {
 			xpath: `/root/hoge`,
 			out:   xmlpi + `<root/>`,
 		},
+		{
+			input: xmlpi + `<root xmlns:x="urn:x"><a id="1" x:id="2"/></root>`,
+			xpath: `//@x:id`,
+			out:   xmlpi + `<root xmlns:x="urn:x"><a id="1"/></root>`,
+		},
 		{
 			input: xmlpi + `<root><hoge/><hoge/><hoge/></root>`,
 			xpath: `/root/hoge`,
@@ -77,5 +84,52 @@ func TestDelete(t *testing.T) {
 			xpath: `//hoge`,
 			out:   xmlpi + `<root><fuga/></root>`,
 		},
+		{ /*default namespace*/
+			input: xmlpi + `<root xmlns="http://m"><hoge/><fuga/></root>`,
+			xpath: `//m:hoge`,
+			ns:    map[string]string{"m": "http://m"},
+			out:   xmlpi + `<root xmlns="http://m"><fuga/></root>`,
+		},
+		{ /*declared on the root*/
+			input: xmlpi + `<root xmlns:x="http://x"><x:hoge/><hoge/></root>`,
+			xpath: `//x:hoge`,
+			out:   xmlpi + `<root xmlns:x="http://x"><hoge/></root>`,
+		},
+		{ /*another prefix for the same namespace*/
+			input: xmlpi + `<root xmlns:x="http://x"><x:hoge/><hoge/></root>`,
+			xpath: `//y:hoge`,
+			ns:    map[string]string{"y": "http://x"},
+			out:   xmlpi + `<root xmlns:x="http://x"><hoge/></root>`,
+		},
+		{ /*overridden*/
+			input: xmlpi + `<root xmlns:x="http://x"><x:hoge/><hoge xmlns="http://y"/></root>`,
+			xpath: `//x:hoge`,
+			ns:    map[string]string{"x": "http://y"},
+			out:   xmlpi + `<root xmlns:x="http://x"><x:hoge/></root>`,
+		},
+		{ /*declared deeper*/
+			input: xmlpi + `<root><fuga xmlns:x="http://x"><x:hoge/></fuga></root>`,
+			xpath: `//x:hoge`,
+			out:   xmlpi + `<root><fuga xmlns:x="http://x"/></root>`,
+		},
+		{
+			input:  xmlpi + `<root xmlns:x="http://x"><hoge/></root>`,
+			xpath:  `//z:hoge`,
+			out:    xmlpi + `<root xmlns:x="http://x"><hoge/></root>`,
+			errout: "xpath: prefix z not defined.\n",
+		},
 	})
 }
+
+func TestParseNamespaces(t *testing.T) {
+	ns, err := main.ParseNamespaces([]string{"m=http://m", " x = http://x?a=b "})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, ns, map[string]string{"m": "http://m", "x": "http://x?a=b"})
+
+	ns, err = main.ParseNamespaces(nil)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, len(ns), 0)
+
+	_, err = main.ParseNamespaces([]string{"http://m"})
+	gotwant.Test(t, err != nil, true)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type diffCmd struct {
+	_ struct{} `help:"eksemel diff a.xml b.xml" usage:"eksemel diff A [B]  (B is read from stdin if omitted)"`
+
+	Format string   `cli:"format=FORMAT" type:"Choice" choices:"text,json" default:"text" help:"text or json"`
+	Ignore []string `cli:"ignore=XPATH" type:"List" help:"nodes not to compare (repeatable)"`
+
+	Namespaces []string `cli:"ns=BINDING" type:"List" help:"bind a namespace prefix=uri for XPath (repeatable)"`
+}
+
+// ErrDiffer is returned by Diff if the documents differ.
+var ErrDiffer = errors.New("documents differ")
+
+// difference is an added, removed or changed node.
+type difference struct {
+	Type string  `json:"type"`
+	Path string  `json:"path"`
+	Old  *string `json:"old,omitempty"`
+	New  *string `json:"new,omitempty"`
+}
+
+// Diff compares two documents and writes differences in format.
+//
+// Whitespace-only texts, leading and trailing whitespace of texts, the XML declaration
+// and the order of attributes are ignored, as well as nodes matched by ignore.
+// Children are aligned by their names (longest common subsequence),
+// so a renamed element is reported as removed and added.
+//
+//   - text: lines of "- PATH: VALUE" (removed), "+ PATH: VALUE" (added), and `~ PATH: "OLD" -> "NEW"` (changed)
+//   - json: an array of objects {"type", "path", "old", "new"}
+//
+// Paths are absolute XPaths in a for removed and changed nodes, and in b for added nodes.
+//
+// ErrDiffer is returned after writing if any difference is found.
+func Diff(a, b io.ReadCloser, output io.Writer, ns map[string]string, ignore []string, format string) error {
+	adoc, err := parseInput(a, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+	bdoc, err := parseInput(b, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+
+	d := differ{
+		ignored:      make(map[*xmlquery.Node]bool),
+		ignoredAttrs: make(map[*xmlquery.Node]map[string]bool),
+	}
+	for _, doc := range []*xmlquery.Node{adoc, bdoc} {
+		for _, expr := range ignore {
+			nodes, err := queryAll(doc, expr, ns)
+			if err != nil {
+				return fmt.Errorf("ignore: %w", err)
+			}
+			d.ignore(nodes)
+		}
+	}
+
+	d.compareChildren(adoc, bdoc)
+
+	if format == "json" {
+		if d.diffs == nil {
+			d.diffs = []difference{}
+		}
+		enc := json.NewEncoder(output)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(d.diffs); err != nil {
+			return err
+		}
+	} else {
+		for _, diff := range d.diffs {
+			switch diff.Type {
+			case "removed":
+				fmt.Fprintf(output, "- %s: %s\n", diff.Path, *diff.Old)
+			case "added":
+				fmt.Fprintf(output, "+ %s: %s\n", diff.Path, *diff.New)
+			default:
+				fmt.Fprintf(output, "~ %s: %q -> %q\n", diff.Path, *diff.Old, *diff.New)
+			}
+		}
+	}
+
+	if len(d.diffs) > 0 {
+		return ErrDiffer
+	}
+	return nil
+}
+
+type differ struct {
+	ignored      map[*xmlquery.Node]bool
+	ignoredAttrs map[*xmlquery.Node]map[string]bool // by qualified names
+
+	diffs []difference
+}
+
+func (d *differ) ignore(nodes []*xmlquery.Node) {
+	for _, n := range nodes {
+		if n.Type != xmlquery.AttributeNode {
+			d.ignored[n] = true
+			continue
+		}
+
+		// attribute nodes are created at each query
+		if d.ignoredAttrs[n.Parent] == nil {
+			d.ignoredAttrs[n.Parent] = make(map[string]bool)
+		}
+		d.ignoredAttrs[n.Parent][qualifiedName(xml.Name{Space: n.Prefix, Local: n.Data})] = true
+	}
+}
+
+func (d *differ) add(typ, path string, old, new *string) {
+	d.diffs = append(d.diffs, difference{Type: typ, Path: path, Old: old, New: new})
+}
+
+// children returns the children of n to compare.
+func (d *differ) children(n *xmlquery.Node) []*xmlquery.Node {
+	var children []*xmlquery.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if d.ignored[c] || isWhitespaceText(c) {
+			continue
+		}
+		if c.Type == xmlquery.DeclarationNode && c.Data == "xml" {
+			continue
+		}
+		children = append(children, c)
+	}
+	return children
+}
+
+func (d *differ) compareChildren(a, b *xmlquery.Node) {
+	for _, p := range alignNodes(d.children(a), d.children(b)) {
+		switch {
+		case p.a != nil && p.b != nil:
+			d.compareNode(p.a, p.b)
+		case p.a != nil:
+			v := diffValue(p.a)
+			d.add("removed", nodePath(p.a), &v, nil)
+		default:
+			v := diffValue(p.b)
+			d.add("added", nodePath(p.b), nil, &v)
+		}
+	}
+}
+
+// nodePair is a pair of aligned nodes. Either is nil if the other has no counterpart.
+type nodePair struct {
+	a, b *xmlquery.Node
+}
+
+// alignNodes aligns ac and bc by the longest common subsequence of their keys (see diffKey).
+// Unpaired nodes of ac come before those of bc.
+func alignNodes(ac, bc []*xmlquery.Node) []nodePair {
+	lcs := make([][]int, len(ac)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bc)+1)
+	}
+	for i := len(ac) - 1; i >= 0; i-- {
+		for j := len(bc) - 1; j >= 0; j-- {
+			if diffKey(ac[i]) == diffKey(bc[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var pairs []nodePair
+	i, j := 0, 0
+	for i < len(ac) || j < len(bc) {
+		switch {
+		case i < len(ac) && j < len(bc) && diffKey(ac[i]) == diffKey(bc[j]):
+			pairs = append(pairs, nodePair{a: ac[i], b: bc[j]})
+			i++
+			j++
+		case i < len(ac) && (j == len(bc) || lcs[i+1][j] >= lcs[i][j+1]):
+			pairs = append(pairs, nodePair{a: ac[i]})
+			i++
+		default:
+			pairs = append(pairs, nodePair{b: bc[j]})
+			j++
+		}
+	}
+	return pairs
+}
+
+func (d *differ) compareNode(a, b *xmlquery.Node) {
+	if a.Type != xmlquery.ElementNode {
+		av, bv := diffText(a), diffText(b)
+		if av != bv {
+			d.add("changed", nodePath(a), &av, &bv)
+		}
+		return
+	}
+
+	aattrs, battrs := d.attrs(a), d.attrs(b)
+	names := make([]string, 0, len(aattrs)+len(battrs))
+	for name := range aattrs {
+		names = append(names, name)
+	}
+	for name := range battrs {
+		if _, found := aattrs[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		av, afound := aattrs[name]
+		bv, bfound := battrs[name]
+		switch {
+		case !afound:
+			d.add("added", nodePath(b)+"/@"+name, nil, &bv)
+		case !bfound:
+			d.add("removed", nodePath(a)+"/@"+name, &av, nil)
+		case av != bv:
+			d.add("changed", nodePath(a)+"/@"+name, &av, &bv)
+		}
+	}
+
+	d.compareChildren(a, b)
+}
+
+// attrs returns attributes of n not ignored.
+func (d *differ) attrs(n *xmlquery.Node) map[string]string {
+	attrs := nodeAttrs(n)
+	for name := range d.ignoredAttrs[n] {
+		delete(attrs, name)
+	}
+	return attrs
+}
+
+// diffKey returns a key to align n with nodes of the other document.
+func diffKey(n *xmlquery.Node) string {
+	switch n.Type {
+	case xmlquery.ElementNode:
+		return "<" + nodeName(n)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return "#text"
+	case xmlquery.DeclarationNode:
+		return "?" + n.Data
+	}
+	return nodeName(n)
+}
+
+// diffText returns the compared value of a non-element node n.
+func diffText(n *xmlquery.Node) string {
+	if n.Type == xmlquery.DeclarationNode {
+		return diffValue(n)
+	}
+	return strings.TrimSpace(nodeText(n))
+}
+
+// diffValue returns n as a compact XML.
+func diffValue(n *xmlquery.Node) string {
+	if n.Type == xmlquery.TextNode || n.Type == xmlquery.CharDataNode {
+		return strings.TrimSpace(nodeText(n))
+	}
+
+	var b bytes.Buffer
+	OutputXML(&b, n, OutputConfig{EmptyElement: true})
+	return b.String()
+}
+
+// Run exits with 1 if the documents differ, and 2 on errors as diff(1).
+func (c diffCmd) Run(args []string) error {
+	exitWithResult(c.diff(args), ErrDiffer)
+	return nil
+}
+
+func (c diffCmd) diff(args []string) error {
+	if len(args) == 0 {
+		return errors.New("input required")
+	}
+
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	a, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	b, _, err := openInput(args[1:])
+	if err != nil {
+		a.Close()
+		return err
+	}
+
+	return Diff(a, b, os.Stdout, ns, c.Ignore, c.Format)
+}
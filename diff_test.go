@@ -0,0 +1,95 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type difftestdata struct {
+	a, b   string
+	ignore []string
+	format string
+
+	err error
+	out string
+}
+
+func TestDiff(t *testing.T) {
+	data := []difftestdata{
+		{
+			a:   xmlpi + "<root>\n  <a x=\"1\" y=\"2\"> text </a>\n</root>",
+			b:   `<root><a y="2" x="1">text</a></root>`,
+			out: ``,
+		},
+		{
+			a:   xmlpi + `<root><a x="1" y="2">text</a><b/><c/></root>`,
+			b:   xmlpi + `<root><a x="3" z="4">new</a><c/><d>d</d></root>`,
+			err: main.ErrDiffer,
+			out: `~ /root/a/@x: "1" -> "3"` + "\n" +
+				`- /root/a/@y: 2` + "\n" +
+				`+ /root/a/@z: 4` + "\n" +
+				`~ /root/a/text(): "text" -> "new"` + "\n" +
+				`- /root/b: <b/>` + "\n" +
+				`+ /root/d: <d>d</d>` + "\n",
+		},
+		{
+			a:   xmlpi + `<root><i/><i/><!--c--></root>`,
+			b:   xmlpi + `<root><i/><i><j/></i><!--d--></root>`,
+			err: main.ErrDiffer,
+			out: `+ /root/i[2]/j: <j/>` + "\n" +
+				`~ /root/comment(): "c" -> "d"` + "\n",
+		},
+		{
+			a:      xmlpi + `<root stamp="1"><time>1</time><a/></root>`,
+			b:      xmlpi + `<root stamp="2"><time>2</time><a/></root>`,
+			ignore: []string{`//time`, `/root/@stamp`},
+			out:    ``,
+		},
+		{
+			a:      xmlpi + `<root><a>1</a></root>`,
+			b:      xmlpi + `<root><b>2</b></root>`,
+			format: "json",
+			err:    main.ErrDiffer,
+			out:    `[{"type":"removed","path":"/root/a","old":"<a>1</a>"},{"type":"added","path":"/root/b","new":"<b>2</b>"}]` + "\n",
+		},
+		{
+			a:      xmlpi + `<root/>`,
+			b:      xmlpi + `<root/>`,
+			format: "json",
+			out:    "[]\n",
+		},
+		{
+			// @x:id is not @id
+			a:      xmlpi + `<root xmlns:x="urn:x"><a id="1" x:id="1"/></root>`,
+			b:      xmlpi + `<root xmlns:x="urn:x"><a id="2" x:id="2"/></root>`,
+			ignore: []string{`//@x:id`},
+			err:    main.ErrDiffer,
+			out:    `~ /root/a/@id: "1" -> "2"` + "\n",
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		format := d.format
+		if format == "" {
+			format = "text"
+		}
+		err := main.Diff(
+			main.NewFakeCloseReader(bytes.NewBufferString(d.a)),
+			main.NewFakeCloseReader(bytes.NewBufferString(d.b)),
+			out,
+			nil,
+			d.ignore,
+			format,
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// DTD is a document type definition: declarations of elements, attributes and entities.
+type DTD struct {
+	// Name is the root element name in DOCTYPE. Empty for an external DTD file.
+	Name string
+	// SystemID is the external DTD in DOCTYPE.
+	SystemID string
+
+	elements map[string]*dtdElement
+	attlists map[string][]*dtdAttr
+	entities map[string]string
+	pes      map[string]string
+}
+
+type dtdElement struct {
+	spec  string
+	kind  string          // EMPTY, ANY, mixed or children
+	names map[string]bool // of mixed
+	re    *regexp.Regexp  // of children, matching names each followed by ','
+}
+
+type dtdAttr struct {
+	name  string
+	typ   string   // CDATA, ID, IDREF, ..., or enumeration
+	enum  []string // of enumeration and NOTATION
+	mode  string   // #REQUIRED, #IMPLIED, #FIXED or empty
+	value string   // default value
+}
+
+func newDTD() *DTD {
+	return &DTD{
+		elements: make(map[string]*dtdElement),
+		attlists: make(map[string][]*dtdAttr),
+		entities: make(map[string]string),
+		pes:      make(map[string]string),
+	}
+}
+
+// ParseDTD parses an external DTD.
+func ParseDTD(r io.Reader) (*DTD, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDTD()
+	if err := d.parseSubset(string(text)); err != nil {
+		return nil, fmt.Errorf("dtd: %w", err)
+	}
+	return d, nil
+}
+
+// LoadDTD reads an external DTD file.
+func LoadDTD(filename string) (*DTD, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseDTD(f)
+}
+
+// parseDoctype parses the content of <!DOCTYPE ...>.
+func parseDoctype(directive string) (*DTD, error) {
+	d := newDTD()
+
+	rest, found := strings.CutPrefix(directive, "DOCTYPE")
+	if !found {
+		return nil, errors.New("dtd: not a DOCTYPE")
+	}
+
+	subset := ""
+	if i := strings.IndexByte(rest, '['); i != -1 {
+		j := strings.LastIndexByte(rest, ']')
+		if j < i {
+			return nil, errors.New("dtd: unclosed internal subset")
+		}
+		subset = rest[i+1 : j]
+		rest = rest[:i]
+	}
+
+	tokens := dtdTokens(rest)
+	if len(tokens) == 0 {
+		return nil, errors.New("dtd: no name in DOCTYPE")
+	}
+	d.Name = tokens[0]
+	switch {
+	case len(tokens) >= 3 && tokens[1] == "SYSTEM":
+		d.SystemID = unquote(tokens[2])
+	case len(tokens) >= 4 && tokens[1] == "PUBLIC":
+		d.SystemID = unquote(tokens[3])
+	}
+
+	if err := d.parseSubset(subset); err != nil {
+		return nil, fmt.Errorf("dtd: %w", err)
+	}
+	return d, nil
+}
+
+var rePERef = regexp.MustCompile(`%([\p{L}_:][\p{L}\p{N}._:\-]*);`)
+
+func (d *DTD) expandPEs(s string) (string, error) {
+	var err error
+	s = rePERef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[1 : len(ref)-1]
+		v, found := d.pes[name]
+		if !found && err == nil {
+			err = fmt.Errorf("parameter entity %q is not declared", name)
+		}
+		return v
+	})
+	return s, err
+}
+
+func (d *DTD) parseSubset(text string) error {
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			return nil
+		}
+
+		switch {
+		case strings.HasPrefix(text, "<!--"):
+			i := strings.Index(text, "-->")
+			if i == -1 {
+				return errors.New("unclosed comment")
+			}
+			text = text[i+3:]
+
+		case strings.HasPrefix(text, "<?"):
+			i := strings.Index(text, "?>")
+			if i == -1 {
+				return errors.New("unclosed processing instruction")
+			}
+			text = text[i+2:]
+
+		case text[0] == '%':
+			loc := rePERef.FindStringIndex(text)
+			if loc == nil || loc[0] != 0 {
+				return fmt.Errorf("unexpected %q", firstLine(text))
+			}
+			expanded, err := d.expandPEs(text[:loc[1]])
+			if err != nil {
+				return err
+			}
+			text = expanded + " " + text[loc[1]:]
+
+		case strings.HasPrefix(text, "<!["):
+			// conditional section
+			open := strings.IndexByte(text[3:], '[')
+			if open == -1 {
+				return errors.New("unclosed conditional section")
+			}
+			keyword, err := d.expandPEs(text[3 : 3+open])
+			if err != nil {
+				return err
+			}
+			body, rest, err := conditionalSection(text[3+open+1:])
+			if err != nil {
+				return err
+			}
+			switch strings.TrimSpace(keyword) {
+			case "INCLUDE":
+				text = body + " " + rest
+			case "IGNORE":
+				text = rest
+			default:
+				return fmt.Errorf("unknown conditional section %q", keyword)
+			}
+
+		case strings.HasPrefix(text, "<!"):
+			end := declEnd(text)
+			if end == -1 {
+				return fmt.Errorf("unclosed declaration %q", firstLine(text))
+			}
+			decl, err := d.expandPEs(text[2:end])
+			if err != nil {
+				return err
+			}
+			text = text[end+1:]
+			if err := d.parseDecl(decl); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unexpected %q", firstLine(text))
+		}
+	}
+}
+
+// conditionalSection splits text after "<![KEYWORD[" into the body and the rest after "]]>".
+func conditionalSection(text string) (body, rest string, err error) {
+	depth := 1
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], "<!["):
+			depth++
+			i += 2
+		case strings.HasPrefix(text[i:], "]]>"):
+			depth--
+			if depth == 0 {
+				return text[:i], text[i+3:], nil
+			}
+			i += 2
+		}
+	}
+	return "", "", errors.New("unclosed conditional section")
+}
+
+// declEnd returns the index of '>' closing a declaration, skipping quoted strings.
+func declEnd(text string) int {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(s, "\n")
+	if len(s) > 40 {
+		s = s[:40] + "..."
+	}
+	return s
+}
+
+// dtdTokens splits s into quoted strings, parenthesized groups (with an occurrence suffix), and words.
+func dtdTokens(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(s[i+1:], c)
+			if j == -1 {
+				tokens = append(tokens, s[i:])
+				return tokens
+			}
+			tokens = append(tokens, s[i:i+j+2])
+			i += j + 2
+
+		case c == '(':
+			depth := 0
+			j := i
+			for ; j < len(s); j++ {
+				if s[j] == '(' {
+					depth++
+				} else if s[j] == ')' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			j++
+			if j < len(s) && strings.IndexByte("?*+", s[j]) != -1 {
+				j++
+			}
+			tokens = append(tokens, s[i:min(j, len(s))])
+			i = j
+
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\r\n(\"'", s[j]) == -1 {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func (d *DTD) parseDecl(decl string) error {
+	tokens := dtdTokens(decl)
+	if len(tokens) < 2 {
+		return fmt.Errorf("invalid declaration <!%s>", decl)
+	}
+
+	switch tokens[0] {
+	case "ELEMENT":
+		if len(tokens) < 3 {
+			return fmt.Errorf("invalid declaration <!%s>", decl)
+		}
+		if _, found := d.elements[tokens[1]]; found {
+			// the first one is binding
+			return nil
+		}
+		e, err := compileContentSpec(tokens[2])
+		if err != nil {
+			return fmt.Errorf("element %s: %w", tokens[1], err)
+		}
+		d.elements[tokens[1]] = e
+
+	case "ATTLIST":
+		elem := tokens[1]
+		for rest := tokens[2:]; len(rest) > 0; {
+			if len(rest) < 3 {
+				return fmt.Errorf("attlist %s: invalid declaration", elem)
+			}
+			a := &dtdAttr{name: rest[0], typ: rest[1]}
+			rest = rest[2:]
+
+			if a.typ == "NOTATION" {
+				a.enum = strings.Split(strings.Trim(rest[0], "() \t\r\n"), "|")
+				rest = rest[1:]
+			} else if strings.HasPrefix(a.typ, "(") {
+				a.enum = strings.Split(strings.Trim(a.typ, "() \t\r\n"), "|")
+				a.typ = "enumeration"
+			}
+			for i := range a.enum {
+				a.enum[i] = strings.TrimSpace(a.enum[i])
+			}
+
+			if len(rest) == 0 {
+				return fmt.Errorf("attlist %s: no default of %s", elem, a.name)
+			}
+			switch rest[0] {
+			case "#REQUIRED", "#IMPLIED":
+				a.mode = rest[0]
+				rest = rest[1:]
+			case "#FIXED":
+				if len(rest) < 2 {
+					return fmt.Errorf("attlist %s: no value of %s", elem, a.name)
+				}
+				a.mode = rest[0]
+				a.value = unquote(rest[1])
+				rest = rest[2:]
+			default:
+				a.value = unquote(rest[0])
+				rest = rest[1:]
+			}
+
+			found := false
+			for _, other := range d.attlists[elem] {
+				if other.name == a.name {
+					found = true
+				}
+			}
+			if !found {
+				d.attlists[elem] = append(d.attlists[elem], a)
+			}
+		}
+
+	case "ENTITY":
+		if tokens[1] == "%" {
+			if len(tokens) >= 4 && tokens[2] != "" {
+				if _, found := d.pes[tokens[2]]; !found {
+					d.pes[tokens[2]] = unquote(tokens[3])
+				}
+			}
+			return nil
+		}
+		if len(tokens) >= 3 && (tokens[2][0] == '"' || tokens[2][0] == '\'') {
+			if _, found := d.entities[tokens[1]]; !found {
+				d.entities[tokens[1]] = unquote(tokens[2])
+			}
+		}
+
+	case "NOTATION":
+
+	default:
+		return fmt.Errorf("unknown declaration <!%s>", tokens[0])
+	}
+
+	return nil
+}
+
+func compileContentSpec(spec string) (*dtdElement, error) {
+	e := &dtdElement{spec: spec}
+
+	s := strings.Join(strings.Fields(spec), "")
+	switch {
+	case s == "EMPTY" || s == "ANY":
+		e.kind = s
+
+	case strings.HasPrefix(s, "(#PCDATA"):
+		e.kind = "mixed"
+		e.names = make(map[string]bool)
+		inner := strings.TrimSuffix(strings.TrimSuffix(s, "*"), ")")
+		for _, name := range strings.Split(inner, "|")[1:] {
+			e.names[name] = true
+		}
+
+	case strings.HasPrefix(s, "("):
+		e.kind = "children"
+
+		var b strings.Builder
+		for i := 0; i < len(s); {
+			switch c := s[i]; c {
+			case '(':
+				b.WriteString("(?:")
+				i++
+			case ')', '|', '?', '*', '+':
+				b.WriteByte(c)
+				i++
+			case ',':
+				i++
+			default:
+				j := i
+				for j < len(s) && strings.IndexByte("()|,?*+", s[j]) == -1 {
+					j++
+				}
+				b.WriteString("(?:" + regexp.QuoteMeta(s[i:j]) + ",)")
+				i = j
+			}
+		}
+
+		re, err := regexp.Compile("^(?:" + b.String() + ")$")
+		if err != nil {
+			return nil, err
+		}
+		e.re = re
+
+	default:
+		return nil, fmt.Errorf("invalid content %q", spec)
+	}
+
+	return e, nil
+}
+
+// merge adds declarations of ext not declared in d.
+func (d *DTD) merge(ext *DTD) {
+	for name, e := range ext.elements {
+		if _, found := d.elements[name]; !found {
+			d.elements[name] = e
+		}
+	}
+	for elem, attrs := range ext.attlists {
+		for _, a := range attrs {
+			found := false
+			for _, other := range d.attlists[elem] {
+				if other.name == a.name {
+					found = true
+				}
+			}
+			if !found {
+				d.attlists[elem] = append(d.attlists[elem], a)
+			}
+		}
+	}
+	for name, v := range ext.entities {
+		if _, found := d.entities[name]; !found {
+			d.entities[name] = v
+		}
+	}
+}
+
+// findDoctype returns the DOCTYPE node of doc, or nil.
+func findDoctype(doc *xmlquery.Node) *xmlquery.Node {
+	nodes := prologNodes(doc)
+	for n := doc.FirstChild; n != nil && n.Type != xmlquery.ElementNode; n = n.NextSibling {
+		nodes = append(nodes, n)
+	}
+	for _, n := range nodes {
+		if n.Type == xmlquery.NotationNode && strings.HasPrefix(n.Data, "DOCTYPE") {
+			return n
+		}
+	}
+	return nil
+}
+
+// errExternalDTD is returned by documentDTD if the external DTD cannot be read.
+var errExternalDTD = errors.New("external DTD")
+
+// documentDTD returns the DTD in DOCTYPE of doc, with its external DTD resolved relative to dir if it is a local file.
+// If the external DTD cannot be read, the DTD of the internal subset is returned with errExternalDTD.
+// It returns nil if doc has no DOCTYPE.
+func documentDTD(doc *xmlquery.Node, dir string) (*DTD, error) {
+	dt := findDoctype(doc)
+	if dt == nil {
+		return nil, nil
+	}
+
+	d, err := parseDoctype(dt.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.SystemID != "" && !strings.Contains(d.SystemID, "://") {
+		ext, err := LoadDTD(filepath.Join(dir, d.SystemID))
+		if err != nil {
+			// with the internal subset
+			return d, fmt.Errorf("%w: %w", errExternalDTD, err)
+		}
+		d.merge(ext)
+	}
+
+	return d, nil
+}
+
+// rawDoctype returns the content of <!DOCTYPE ...> in src as it is.
+// encoding/xml drops comments in it.
+func rawDoctype(src []byte) (string, bool) {
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	dec.Strict = false
+	dec.CharsetReader = decodedCharsetReader
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err != nil {
+			return "", false
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return "", false
+		case xml.Directive:
+			if bytes.HasPrefix(tok, []byte("DOCTYPE")) {
+				raw := src[start:dec.InputOffset()]
+				return string(raw[2 : len(raw)-1]), true
+			}
+		}
+	}
+}
+
+// applyDefaults adds default attribute values to elements of doc.
+func (d *DTD) applyDefaults(doc *xmlquery.Node) {
+	var walk func(n *xmlquery.Node)
+	walk = func(n *xmlquery.Node) {
+		if n.Type == xmlquery.ElementNode {
+			for _, a := range d.attlists[nodeName(n)] {
+				if a.mode != "" && a.mode != "#FIXED" {
+					continue
+				}
+				if _, found := nodeAttrs(n)[a.name]; !found {
+					setAttr(n, a.name, a.value)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+// Validate returns violations of doc against d.
+func (d *DTD) Validate(doc *xmlquery.Node) []Violation {
+	v := &dtdValidator{dtd: d, ids: make(map[string]bool)}
+
+	root := rootElement(doc)
+	if root == nil {
+		v.report(doc, "no root element")
+		return v.violations
+	}
+	if d.Name != "" && nodeName(root) != d.Name {
+		v.report(root, fmt.Sprintf("root element must be <%s>", d.Name))
+	}
+
+	v.element(root)
+
+	for _, ref := range v.idrefs {
+		if !v.ids[ref.id] {
+			v.violations = append(v.violations, Violation{Path: ref.path, Message: fmt.Sprintf("%s @%s refers to %q, which is not the ID of any element", ref.typ, ref.attr, ref.id)})
+		}
+	}
+
+	return v.violations
+}
+
+type dtdValidator struct {
+	dtd        *DTD
+	violations []Violation
+
+	ids    map[string]bool
+	idrefs []idref
+}
+
+func (v *dtdValidator) report(n *xmlquery.Node, msg string) {
+	v.violations = append(v.violations, Violation{Path: nodePath(n), Message: msg})
+}
+
+func (v *dtdValidator) element(n *xmlquery.Node) {
+	name := nodeName(n)
+
+	e := v.dtd.elements[name]
+	if e == nil {
+		v.report(n, fmt.Sprintf("element <%s> is not declared", name))
+	} else {
+		v.content(n, e)
+	}
+
+	v.attributes(n)
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode {
+			v.element(c)
+		}
+	}
+}
+
+func (v *dtdValidator) content(n *xmlquery.Node, e *dtdElement) {
+	switch e.kind {
+	case "EMPTY":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != xmlquery.CommentNode && c.Type != xmlquery.DeclarationNode {
+				v.report(n, fmt.Sprintf("element <%s> must be empty", nodeName(n)))
+				return
+			}
+		}
+
+	case "mixed":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == xmlquery.ElementNode && !e.names[nodeName(c)] {
+				v.report(c, fmt.Sprintf("element <%s> is not allowed in <%s>", nodeName(c), nodeName(n)))
+			}
+		}
+
+	case "children":
+		var names strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.Type == xmlquery.ElementNode:
+				names.WriteString(nodeName(c) + ",")
+			case (c.Type == xmlquery.TextNode || c.Type == xmlquery.CharDataNode) && !isWhitespaceText(c):
+				v.report(c, "text is not allowed")
+			}
+		}
+		if !e.re.MatchString(names.String()) {
+			v.report(n, fmt.Sprintf("content does not match %s", strings.Join(strings.Fields(e.spec), "")))
+		}
+	}
+}
+
+func (v *dtdValidator) attributes(n *xmlquery.Node) {
+	decls := v.dtd.attlists[nodeName(n)]
+	path := nodePath(n)
+
+	present := make(map[string]bool)
+	for _, attr := range n.Attr {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		if attr.Name.Space == "xmlns" || name == "xmlns" {
+			continue
+		}
+		apath := path + "/@" + name
+
+		var a *dtdAttr
+		for _, decl := range decls {
+			if decl.name == name {
+				a = decl
+			}
+		}
+		if a == nil {
+			v.violations = append(v.violations, Violation{Path: apath, Message: "attribute is not declared"})
+			continue
+		}
+		present[name] = true
+
+		value := attr.Value
+		if a.typ != "CDATA" {
+			value = collapseSpace(value)
+		}
+		if msg := v.attrValue(a, value, apath); msg != "" {
+			v.violations = append(v.violations, Violation{Path: apath, Message: msg})
+			continue
+		}
+		if a.mode == "#FIXED" && value != a.value {
+			v.violations = append(v.violations, Violation{Path: apath, Message: fmt.Sprintf("%q must be %q", value, a.value)})
+		}
+	}
+
+	for _, a := range decls {
+		if a.mode == "#REQUIRED" && !present[a.name] {
+			v.report(n, fmt.Sprintf("missing attribute @%s", a.name))
+		}
+	}
+}
+
+// idref is an ID referred by an IDREF or IDREFS attribute, checked after all IDs are known.
+type idref struct {
+	path string // of the attribute
+	typ  string // IDREF or IDREFS
+	attr string
+	id   string
+}
+
+// attrValue checks value of a, and returns a message if invalid.
+func (v *dtdValidator) attrValue(a *dtdAttr, value, path string) string {
+	switch a.typ {
+	case "ID":
+		if !reName.MatchString(value) {
+			return fmt.Sprintf("%q is not a valid ID", value)
+		}
+		if v.ids[value] {
+			return fmt.Sprintf("duplicate ID %q", value)
+		}
+		v.ids[value] = true
+
+	case "IDREF", "ENTITY":
+		if !reName.MatchString(value) {
+			return fmt.Sprintf("%q is not a valid %s", value, a.typ)
+		}
+		if a.typ == "IDREF" {
+			v.idrefs = append(v.idrefs, idref{path: path, typ: a.typ, attr: a.name, id: value})
+		}
+
+	case "IDREFS", "ENTITIES":
+		if !isListOf(value, reName.MatchString) {
+			return fmt.Sprintf("%q is not a valid %s", value, a.typ)
+		}
+		if a.typ == "IDREFS" {
+			for _, ref := range strings.Fields(value) {
+				v.idrefs = append(v.idrefs, idref{path: path, typ: a.typ, attr: a.name, id: ref})
+			}
+		}
+
+	case "NMTOKEN":
+		if !reNMTOKEN.MatchString(value) {
+			return fmt.Sprintf("%q is not a valid NMTOKEN", value)
+		}
+
+	case "NMTOKENS":
+		if !isListOf(value, reNMTOKEN.MatchString) {
+			return fmt.Sprintf("%q is not a valid NMTOKENS", value)
+		}
+
+	case "enumeration", "NOTATION":
+		for _, e := range a.enum {
+			if e == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q is not one of (%s)", value, strings.Join(a.enum, "|"))
+	}
+
+	return ""
+}
@@ -0,0 +1,179 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+const testDoctype = `<!DOCTYPE lib [
+  <!-- a library -->
+  <!ENTITY % id "id ID #REQUIRED">
+  <!ENTITY pub "ACME">
+  <!ELEMENT lib (book+, author*)>
+  <!ELEMENT book (title, note?)>
+  <!ATTLIST book %id; lang (en|ja) "en" by IDREFS #IMPLIED>
+  <!ELEMENT title (#PCDATA|em)*>
+  <!ELEMENT em (#PCDATA)>
+  <!ELEMENT note EMPTY>
+  <!ELEMENT author (#PCDATA)>
+  <!ATTLIST author id ID #REQUIRED kind CDATA #FIXED "person">
+]>`
+
+func TestDTD(t *testing.T) {
+	data := []struct {
+		input string
+		err   error
+		out   string
+	}{
+		{
+			input: testDoctype + `<lib><book id="b1" by="a1"><title>Go <em>&pub;</em></title><note/></book><author id="a1">me</author></lib>`,
+			out:   ``,
+		},
+		{
+			input: testDoctype + `<lib><book id="b1" lang="fr"><note>x</note></book><author id="b1" kind="org"/></lib>`,
+			err:   main.ErrInvalid,
+			out: `/lib/book: content does not match (title,note?)` + "\n" +
+				`/lib/book/@lang: "fr" is not one of (en|ja)` + "\n" +
+				`/lib/book/note: element <note> must be empty` + "\n" +
+				`/lib/author/@id: duplicate ID "b1"` + "\n" +
+				`/lib/author/@kind: "org" must be "person"` + "\n",
+		},
+		{
+			input: testDoctype + `<lib><book by="x y"><title><b/></title></book><magazine/></lib>`,
+			err:   main.ErrInvalid,
+			out: `/lib: content does not match (book+,author*)` + "\n" +
+				`/lib/book: missing attribute @id` + "\n" +
+				`/lib/book/title/b: element <b> is not allowed in <title>` + "\n" +
+				`/lib/book/title/b: element <b> is not declared` + "\n" +
+				`/lib/magazine: element <magazine> is not declared` + "\n" +
+				`/lib/book/@by: IDREFS @by refers to "x", which is not the ID of any element` + "\n" +
+				`/lib/book/@by: IDREFS @by refers to "y", which is not the ID of any element` + "\n",
+		},
+		{
+			// only the missing one of several IDs
+			input: testDoctype + `<lib><book id="b1" by="a1 zz b1"><title/></book><author id="a1">me</author></lib>`,
+			err:   main.ErrInvalid,
+			out:   `/lib/book/@by: IDREFS @by refers to "zz", which is not the ID of any element` + "\n",
+		},
+		{
+			input: testDoctype + `<book id="b1"><title/></book>`,
+			err:   main.ErrInvalid,
+			out:   `/book: root element must be <lib>` + "\n",
+		},
+		{
+			input: `<lib/>`,
+			err:   main.ErrInvalid,
+			out:   `/: no DOCTYPE` + "\n",
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.Validate(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, main.DoctypeValidator{})
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestLoadDTD(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "list.dtd"), []byte(`<!ENTITY % strict "INCLUDE">
+<![%strict;[
+  <!ELEMENT list item>
+]]>
+<!ELEMENT list (item+)>
+<!ELEMENT item (#PCDATA)>
+<!ATTLIST item n NMTOKEN "1">
+`), 0644)
+
+	_, err := main.LoadDTD(filepath.Join(dir, "list.dtd"))
+	gotwant.Test(t, err.Error(), `dtd: element list: invalid content "item"`)
+
+	os.WriteFile(filepath.Join(dir, "list.dtd"), []byte(`<!ENTITY % strict "IGNORE">
+<![%strict;[
+  <!ELEMENT list item>
+]]>
+<!ELEMENT list (item+)>
+<!ELEMENT item (#PCDATA)>
+<!ATTLIST item n NMTOKEN "1">
+`), 0644)
+
+	dtd, err := main.LoadDTD(filepath.Join(dir, "list.dtd"))
+	gotwant.TestError(t, err, nil)
+
+	out := &bytes.Buffer{}
+	err = main.Validate(main.NewFakeCloseReader(bytes.NewBufferString(`<list><item n="a b"/></list>`)), out, dtd)
+	gotwant.TestError(t, err, main.ErrInvalid)
+	gotwant.Test(t, out.String(), `/list/item/@n: "a b" is not a valid NMTOKEN`+"\n")
+
+	// the external DTD in DOCTYPE, relative to the document
+	out.Reset()
+	err = main.Validate(main.NewFakeCloseReader(bytes.NewBufferString(`<!DOCTYPE list SYSTEM "list.dtd" [
+  <!ATTLIST item m CDATA #REQUIRED>
+]><list/>`)), out, main.DoctypeValidator{Dir: dir})
+	gotwant.TestError(t, err, main.ErrInvalid)
+	gotwant.Test(t, out.String(), `/list: content does not match (item+)`+"\n")
+}
+
+func TestDoctypeOutput(t *testing.T) {
+	src := `<!DOCTYPE r [
+  <!-- kept -->
+  <!ENTITY e "entity">
+  <!ATTLIST a x CDATA "1" y CDATA #IMPLIED>
+]>
+<!-- before the root -->
+<r><a>&e;</a><a x="2"/></r>`
+
+	in, out, errout := prepare(src)
+	err := main.Delete(main.NewFakeCloseReader(in), out, errout, `//nothing`, nil, main.OutputConfig{EmptyElement: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?><!DOCTYPE r [
+  <!-- kept -->
+  <!ENTITY e "entity">
+  <!ATTLIST a x CDATA "1" y CDATA #IMPLIED>
+]><!-- before the root --><r><a>entity</a><a x="2"/></r>`)
+
+	in, out, errout = prepare(src)
+	err = main.Delete(main.NewFakeCloseReader(in), out, errout, `/r/a[2]`, nil, main.OutputConfig{EmptyElement: true, DTDDefaults: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?><!DOCTYPE r [
+  <!-- kept -->
+  <!ENTITY e "entity">
+  <!ATTLIST a x CDATA "1" y CDATA #IMPLIED>
+]><!-- before the root --><r><a x="1">entity</a></r>`)
+}
+
+func TestDTDDefaultsExternal(t *testing.T) {
+	// the external DTD is next to the input, not in the current directory
+	dir := filepath.Join(t.TempDir(), "sub")
+	os.Mkdir(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "r.dtd"), []byte(`<!ATTLIST a x CDATA "1">`), 0644)
+
+	const src = `<!DOCTYPE r SYSTEM "r.dtd" [
+  <!ATTLIST a y CDATA "2">
+]><r><a/></r>`
+
+	in, out, errout := prepare(src)
+	err := main.Delete(main.NewFakeCloseReader(in), out, errout, `//nothing`, nil, main.OutputConfig{EmptyElement: true, DTDDefaults: true, InputDir: dir})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?><!DOCTYPE r SYSTEM "r.dtd" [
+  <!ATTLIST a y CDATA "2">
+]><r><a y="2" x="1"/></r>`)
+
+	// an unreachable external DTD is skipped
+	in, out, errout = prepare(src)
+	err = main.Delete(main.NewFakeCloseReader(in), out, errout, `//nothing`, nil, main.OutputConfig{EmptyElement: true, DTDDefaults: true, InputDir: t.TempDir()})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?><!DOCTYPE r SYSTEM "r.dtd" [
+  <!ATTLIST a y CDATA "2">
+]><r><a y="2"/></r>`)
+}
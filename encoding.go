@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// textEncoding is a character encoding of an input or an output.
+type textEncoding struct {
+	name string            // as declared or given, or detected
+	enc  encoding.Encoding // nil for UTF-8
+	bom  bool              // UTF-8 with a BOM (UTF-16 writes one by itself, and UTF-16LE and UTF-16BE write none)
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+var reDeclaredEncoding = regexp.MustCompile(`^<\?xml\s[^>]*?encoding\s*=\s*["']([A-Za-z][A-Za-z0-9._\-]*)["']`)
+
+// lookupEncoding returns the encoding by an IANA or WHATWG name (nil for UTF-8).
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	if strings.EqualFold(name, "UTF-8") || strings.EqualFold(name, "UTF8") {
+		return nil, nil
+	}
+
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil || enc == nil {
+		enc, err = htmlindex.Get(name)
+	}
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("encoding: %q is not supported", name)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// decodeInput returns a reader of r converted into UTF-8.
+// The encoding is detected by a BOM, or by the XML declaration.
+func decodeInput(r io.Reader) (io.Reader, *textEncoding, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(1024)
+
+	te := &textEncoding{name: "UTF-8"}
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		br.Discard(len(bomUTF8))
+		te.bom = true
+		return br, te, nil
+
+	case bytes.HasPrefix(head, bomUTF16LE):
+		te.name, te.enc = "UTF-16", unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(head, bomUTF16BE):
+		te.name, te.enc = "UTF-16", unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(head, []byte{'<', 0, '?', 0}):
+		te.name, te.enc = "UTF-16LE", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.HasPrefix(head, []byte{0, '<', 0, '?'}):
+		te.name, te.enc = "UTF-16BE", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+
+	default:
+		m := reDeclaredEncoding.FindSubmatch(head)
+		if m == nil {
+			return br, te, nil
+		}
+		enc, err := lookupEncoding(string(m[1]))
+		if err != nil {
+			return nil, nil, err
+		}
+		te.name, te.enc = string(m[1]), enc
+		if enc == nil {
+			return br, te, nil
+		}
+	}
+
+	return transform.NewReader(br, te.enc.NewDecoder()), te, nil
+}
+
+// decodedCharsetReader is xml.Decoder.CharsetReader for an input converted by decodeInput.
+func decodedCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
+
+// outputEncoding returns the encoding to write in by config: Encoding, or the encoding of the input.
+// With config.BOM, a BOM is written in UTF-8. It does not change other encodings.
+func (config OutputConfig) outputEncoding() *textEncoding {
+	te := textEncoding{name: "UTF-8"}
+	if config.encoding != nil {
+		te = *config.encoding
+	}
+	if config.Encoding != "" {
+		if enc, err := lookupEncoding(config.Encoding); err == nil {
+			te = textEncoding{name: config.Encoding, enc: enc}
+		}
+	}
+	if config.BOM && te.enc == nil {
+		te.bom = true
+	}
+	return &te
+}
+
+// encodeOutput returns a writer converting UTF-8 into te, and close to flush it.
+// Characters not in te are written as character references.
+func encodeOutput(w io.Writer, te *textEncoding) (ew io.Writer, close func() error) {
+	if te.bom {
+		w.Write(bomUTF8)
+	}
+	if te.enc == nil {
+		return w, func() error { return nil }
+	}
+
+	tw := transform.NewWriter(w, encoding.HTMLEscapeUnsupported(te.enc.NewEncoder()))
+	return tw, tw.Close
+}
+
+// setDeclaredEncoding sets the encoding of the XML declaration of doc to name,
+// adding a declaration if there is none and name is not UTF-8.
+func setDeclaredEncoding(doc *xmlquery.Node, name string) {
+	decl := doc.FirstChild
+	if decl == nil || decl.Type != xmlquery.DeclarationNode || decl.Data != "xml" {
+		if strings.EqualFold(name, "UTF-8") {
+			return
+		}
+
+		decl = &xmlquery.Node{
+			Type: xmlquery.DeclarationNode,
+			Data: "xml",
+			Attr: []xmlquery.Attr{{Name: xml.Name{Local: "version"}, Value: "1.0"}},
+		}
+		if doc.FirstChild == nil {
+			xmlquery.AddChild(doc, decl)
+		} else {
+			InsertNode(doc.FirstChild, decl, PositionBefore)
+		}
+	}
+
+	if i := findAttr(decl, "", "encoding"); i != -1 {
+		decl.Attr[i].Value = name
+		return
+	}
+
+	// encoding comes between version and standalone
+	attr := xmlquery.Attr{Name: xml.Name{Local: "encoding"}, Value: name}
+	i := findAttr(decl, "", "version") + 1
+	decl.Attr = append(decl.Attr[:i], append([]xmlquery.Attr{attr}, decl.Attr[i:]...)...)
+}
+
+// textOutput is output options of a command writing text other than XML (e.g. to-json).
+type textOutput struct {
+	OutputEncoding string `cli:"output-encoding=ENCODING" type:"Encoding" help:"write in ENCODING (UTF-8, Shift_JIS, EUC-JP, UTF-16, ...) (default: UTF-8)"`
+	BOM            bool   `cli:"bom" help:"write a BOM in UTF-8"`
+	EOL            string `cli:"eol" type:"Choice" choices:"lf,crlf" default:"lf" help:"line endings, lf or crlf"`
+
+	Output string `cli:"output,o=FILE" help:"write to FILE instead of stdout"`
+}
+
+// write calls fn with stdout or the file of --output, converting what fn writes into the encoding and line endings.
+// Characters not in the encoding are errors, as text other than XML has no character references.
+func (t textOutput) write(fn func(io.Writer) error) error {
+	config := OutputConfig{Encoding: t.OutputEncoding, BOM: t.BOM, EOL: t.EOL}
+	te := config.outputEncoding()
+
+	encoded := func(w io.Writer) error {
+		if te.bom {
+			w.Write(bomUTF8)
+		}
+		if te.enc == nil {
+			return fn(newEOLWriter(w, config.lineEnding()))
+		}
+
+		tw := transform.NewWriter(w, te.enc.NewEncoder())
+		if err := fn(newEOLWriter(tw, config.lineEnding())); err != nil {
+			return err
+		}
+		return tw.Close()
+	}
+
+	if t.Output != "" {
+		return WriteFile(t.Output, encoded)
+	}
+	return encoded(os.Stdout)
+}
@@ -0,0 +1,104 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, enc encoding.Encoding, s string) string {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestEncoding(t *testing.T) {
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+
+	data := []struct {
+		input    string
+		encoding string
+		bom      bool
+		err      string
+		out      string
+	}{
+		{
+			input: encode(t, japanese.ShiftJIS, `<?xml version="1.0" encoding="Shift_JIS"?><r><a>日本語</a><b/></r>`),
+			out:   encode(t, japanese.ShiftJIS, `<?xml version="1.0" encoding="Shift_JIS"?><r><a>日本語</a></r>`),
+		},
+		{
+			input: encode(t, japanese.EUCJP, `<?xml version='1.0' encoding='EUC-JP' standalone='yes'?><r><a>日本語</a><b/></r>`),
+			out:   encode(t, japanese.EUCJP, `<?xml version="1.0" encoding="EUC-JP" standalone="yes"?><r><a>日本語</a></r>`),
+		},
+		{
+			input:    encode(t, japanese.ShiftJIS, `<?xml version="1.0" encoding="Shift_JIS" standalone="yes"?><r><a>日本語</a><b/></r>`),
+			encoding: "UTF-8",
+			out:      `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><r><a>日本語</a></r>`,
+		},
+		{
+			input: encode(t, utf16, `<?xml version="1.0" encoding="UTF-16"?><r><a>日本語</a><b/></r>`),
+			out:   encode(t, utf16, `<?xml version="1.0" encoding="UTF-16"?><r><a>日本語</a></r>`),
+		},
+		{
+			// UTF-16LE and UTF-16BE have no BOM, even with BOM
+			input: encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), `<?xml version="1.0" encoding="UTF-16LE"?><r><b/></r>`),
+			bom:   true,
+			out:   encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), `<?xml version="1.0" encoding="UTF-16LE"?><r/>`),
+		},
+		{
+			input:    `<r><b/></r>`,
+			encoding: "UTF-16BE",
+			out:      encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), `<?xml version="1.0" encoding="UTF-16BE"?><r/>`),
+		},
+		{
+			input:    `<r><b/></r>`,
+			encoding: "UTF-16",
+			bom:      true,
+			out:      "\xFE\xFF" + encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), `<?xml version="1.0" encoding="UTF-16"?><r/>`),
+		},
+		{
+			input: "\xEF\xBB\xBF<r><a>日本語</a><b/></r>",
+			out:   "\xEF\xBB\xBF" + `<?xml version="1.0"?><r><a>日本語</a></r>`,
+		},
+		{
+			input:    `<r><a>日本語 ©</a><b/></r>`,
+			encoding: "Shift_JIS",
+			out:      encode(t, japanese.ShiftJIS, `<?xml version="1.0" encoding="Shift_JIS"?><r><a>日本語 &#169;</a></r>`),
+		},
+		{
+			input: `<?xml version="1.0" encoding="x-unknown"?><r/>`,
+			err:   `input: encoding: "x-unknown" is not supported`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		out := &bytes.Buffer{}
+		err := main.Delete(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, &bytes.Buffer{}, `//b`, nil, main.OutputConfig{EmptyElement: true, Encoding: d.encoding, BOM: d.bom})
+		if d.err != "" {
+			gotwant.Test(t, err.Error(), d.err, gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestEncodingGet(t *testing.T) {
+	input := encode(t, japanese.ShiftJIS, `<?xml version="1.0" encoding="Shift_JIS"?><r><a>日本語</a></r>`)
+
+	out := &bytes.Buffer{}
+	err := main.Get(main.NewFakeCloseReader(bytes.NewBufferString(input)), out, &bytes.Buffer{}, `//a`, nil, false, "\n", "outer-xml", nil, false, main.OutputConfig{EmptyElement: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), "<a>日本語</a>\n")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// Line endings of OutputConfig.EOL
+const (
+	EOLPreserve = "preserve" // as the input
+	EOLLF       = "lf"
+	EOLCRLF     = "crlf"
+)
+
+// detectEOL returns the line ending of the first line of src, "\n" if none.
+func detectEOL(src []byte) string {
+	i := bytes.IndexByte(src, '\n')
+	if i > 0 && src[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// lineEnding returns the line ending to write by config.EOL.
+func (config OutputConfig) lineEnding() string {
+	switch config.EOL {
+	case EOLLF:
+		return "\n"
+	case EOLCRLF:
+		return "\r\n"
+	}
+	if config.eol != "" {
+		return config.eol
+	}
+	return "\n"
+}
+
+// eolWriter writes each of CRLF, LF and CR as eol.
+type eolWriter struct {
+	w   io.Writer
+	eol []byte
+	cr  bool // the last byte written was CR
+}
+
+func newEOLWriter(w io.Writer, eol string) *eolWriter {
+	return &eolWriter{w: w, eol: []byte(eol)}
+}
+
+func (w *eolWriter) Write(p []byte) (int, error) {
+	var b bytes.Buffer
+	for _, c := range p {
+		switch {
+		case c == '\r':
+			b.Write(w.eol)
+			w.cr = true
+			continue
+		case c == '\n' && w.cr:
+			// CRLF, already written
+		case c == '\n':
+			b.Write(w.eol)
+		default:
+			b.WriteByte(c)
+		}
+		w.cr = false
+	}
+
+	if _, err := w.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
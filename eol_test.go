@@ -0,0 +1,55 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestEOL(t *testing.T) {
+	crlf := "<?xml version=\"1.0\"?>\r\n<r>\r\n<!-- a\r\nb --><a>x\r\ny</a><b/></r>\r\n"
+
+	data := []struct {
+		input  string
+		config main.OutputConfig
+		out    string
+	}{
+		{
+			input:  crlf,
+			config: main.OutputConfig{Indent: "  ", EmptyElement: true},
+			out:    "<?xml version=\"1.0\"?>\r\n<r>\r\n  <!-- a\r\nb -->\r\n  <a>\r\n    x\r\ny\r\n  </a>\r\n</r>\r\n",
+		},
+		{
+			input:  crlf,
+			config: main.OutputConfig{Indent: "  ", EmptyElement: true, EOL: main.EOLLF},
+			out:    "<?xml version=\"1.0\"?>\n<r>\n  <!-- a\nb -->\n  <a>\n    x\ny\n  </a>\n</r>\n",
+		},
+		{
+			input:  crlf,
+			config: main.OutputConfig{EmptyElement: true, Preserve: true, EOL: main.EOLLF},
+			out:    "<?xml version=\"1.0\"?>\n<r>\n<!-- a\nb --><a>x\ny</a></r>\n",
+		},
+		{
+			input:  "<r>\n<b/></r>",
+			config: main.OutputConfig{EmptyElement: true, EOL: main.EOLCRLF, BOM: true},
+			out:    "\xEF\xBB\xBF<?xml version=\"1.0\"?><r></r>",
+		},
+		{
+			input:  "<r>\n<!--\n--><b/></r>",
+			config: main.OutputConfig{EmptyElement: true, Preserve: true, EOL: main.EOLCRLF},
+			out:    "<r>\r\n<!--\r\n--></r>",
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		out := &bytes.Buffer{}
+		err := main.Delete(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, &bytes.Buffer{}, `//b`, nil, d.config)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// Escaping modes of OutputConfig.Escape
+const (
+	EscapeMinimal  = "minimal"  // only what XML requires
+	EscapeASCII    = "ascii"    // and non-ASCII characters as character references
+	EscapePreserve = "preserve" // untouched texts and attribute values as they were in the source
+)
+
+// escapeText escapes s for a text node.
+// A CR is escaped, since a literal one would be read as a line feed.
+func escapeText(s string, ascii bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '\r':
+			b.WriteString("&#xD;")
+		case ascii && r > 0x7F:
+			fmt.Fprintf(&b, "&#x%X;", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escapeAttr escapes s for an attribute value quoted by quote.
+// Whitespace other than spaces is escaped, since it would be read as a space.
+func escapeAttr(s string, quote byte, ascii bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"' && quote == '"':
+			b.WriteString("&quot;")
+		case r == '\'' && quote == '\'':
+			b.WriteString("&apos;")
+		case r == '\t':
+			b.WriteString("&#x9;")
+		case r == '\n':
+			b.WriteString("&#xA;")
+		case r == '\r':
+			b.WriteString("&#xD;")
+		case ascii && r > 0x7F:
+			fmt.Fprintf(&b, "&#x%X;", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unescapeXML decodes the predefined entities of XML and character references in s.
+// Other references are left as they are.
+func unescapeXML(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '&')
+		if i == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+
+		end := strings.IndexByte(s, ';')
+		if end == -1 {
+			b.WriteString(s)
+			return b.String()
+		}
+		if r, ok := xmlReference(s[1:end]); ok {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(s[:end+1])
+		}
+		s = s[end+1:]
+	}
+}
+
+// xmlReference returns the character of a predefined entity or a character reference named name (without & and ;).
+func xmlReference(name string) (rune, bool) {
+	switch name {
+	case "amp":
+		return '&', true
+	case "lt":
+		return '<', true
+	case "gt":
+		return '>', true
+	case "quot":
+		return '"', true
+	case "apos":
+		return '\'', true
+	}
+
+	var n uint64
+	var err error
+	switch {
+	case strings.HasPrefix(name, "#x"):
+		n, err = strconv.ParseUint(name[2:], 16, 32)
+	case strings.HasPrefix(name, "#"):
+		n, err = strconv.ParseUint(name[1:], 10, 32)
+	default:
+		return 0, false
+	}
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// cdata returns s in CDATA sections, split at each "]]>".
+func cdata(s string) string {
+	return "<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
+// quote returns the quote character of attribute values by config.Quote.
+func (config OutputConfig) quote() byte {
+	if config.Quote == QuoteSingle {
+		return '\''
+	}
+	return '"'
+}
+
+// text returns the text s of n escaped by config.
+// With EscapePreserve, the source text of n is returned if n is untouched.
+func (config OutputConfig) text(n *xmlquery.Node, s string) string {
+	if config.Escape == EscapePreserve && config.source != nil {
+		if sn := config.source.nodes[n]; sn != nil && sn.own == ownSnapshot(n) && n.Type == xmlquery.TextNode {
+			if s != n.Data {
+				// trimmed
+				return strings.TrimSpace(sn.raw)
+			}
+			return sn.raw
+		}
+	}
+	return escapeText(s, config.Escape == EscapeASCII)
+}
+
+// writeAttrs writes attributes of the element n escaped by config.
+func writeAttrs(b *bufio.Writer, n *xmlquery.Node, config OutputConfig) {
+	for _, a := range config.attrs(n) {
+		b.WriteByte(' ')
+		b.WriteString(a)
+	}
+}
+
+// attrs returns attributes of the element n as name="value", escaped and ordered by config.
+// With EscapePreserve, values unchanged from the source are written as they were.
+func (config OutputConfig) attrs(n *xmlquery.Node) []string {
+	var raw map[string]string
+	if config.Escape == EscapePreserve && config.source != nil {
+		if sn := config.source.nodes[n]; sn != nil {
+			raw = rawAttrValues(sn.raw)
+		}
+	}
+
+	attrs := n.Attr
+	if config.AttrOrder == AttrOrderAlphabetical {
+		attrs = slices.Clone(attrs)
+		slices.SortStableFunc(attrs, func(a, b xmlquery.Attr) int {
+			return strings.Compare(qualifiedName(a.Name), qualifiedName(b.Name))
+		})
+	}
+
+	q := string(config.quote())
+	texts := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		name := qualifiedName(attr.Name)
+		if v, found := raw[name]; found && unescapeXML(v[1:len(v)-1]) == attr.Value {
+			texts = append(texts, name+"="+v)
+			continue
+		}
+		texts = append(texts, name+"="+q+escapeAttr(attr.Value, q[0], config.Escape == EscapeASCII)+q)
+	}
+	return texts
+}
+
+// writePIAttrs writes pseudo-attributes of a processing instruction, which are not escaped.
+func writePIAttrs(b *bufio.Writer, attrs []xmlquery.Attr) {
+	for _, attr := range attrs {
+		b.WriteByte(' ')
+		writeName(b, attr.Name.Space, attr.Name.Local)
+		b.WriteByte('=')
+		q := "\""
+		if strings.Contains(attr.Value, q) {
+			q = "'"
+		}
+		b.WriteString(q + attr.Value + q)
+	}
+}
+
+// rawAttrValues returns the quoted values of attributes in a start tag by their names.
+func rawAttrValues(tag string) map[string]string {
+	values := make(map[string]string)
+
+	tag = strings.TrimPrefix(tag, "<")
+	i := strings.IndexAny(tag, " \t\r\n/>")
+	if i == -1 {
+		return values
+	}
+	tag = tag[i:]
+
+	for {
+		tag = strings.TrimLeft(tag, " \t\r\n")
+		eq := strings.IndexByte(tag, '=')
+		if eq == -1 {
+			return values
+		}
+		name := strings.TrimSpace(tag[:eq])
+		tag = strings.TrimLeft(tag[eq+1:], " \t\r\n")
+		if tag == "" || tag[0] != '"' && tag[0] != '\'' {
+			return values
+		}
+		end := strings.IndexByte(tag[1:], tag[0])
+		if end == -1 {
+			return values
+		}
+		values[name] = tag[:end+2]
+		tag = tag[end+2:]
+	}
+}
@@ -0,0 +1,58 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestEscape(t *testing.T) {
+	const src = xmlpi + `<r a="1&#10;2&#9;'&quot;" b='&apos;x&apos;'><t>it's "é" &lt;&amp;&gt; &#x263A;</t><c><![CDATA[a]]]]><![CDATA[>b]]></c><d/></r>`
+
+	data := []struct {
+		config main.OutputConfig
+		out    string
+	}{
+		{
+			config: main.OutputConfig{EmptyElement: true},
+			out:    xmlpi + `<r a="1&#xA;2&#x9;'&quot;" b="'x'"><t>it's "é" &lt;&amp;&gt; ☺</t><c><![CDATA[a]]]]><![CDATA[>b]]></c></r>`,
+		},
+		{
+			config: main.OutputConfig{EmptyElement: true, Escape: main.EscapeASCII, Quote: main.QuoteSingle},
+			out:    xmlpi + `<r a='1&#xA;2&#x9;&apos;"' b='&apos;x&apos;'><t>it's "&#xE9;" &lt;&amp;&gt; &#x263A;</t><c><![CDATA[a]]]]><![CDATA[>b]]></c></r>`,
+		},
+		{
+			config: main.OutputConfig{EmptyElement: true, Escape: main.EscapePreserve},
+			out:    xmlpi + `<r a="1&#10;2&#9;'&quot;" b='&apos;x&apos;'><t>it's "é" &lt;&amp;&gt; &#x263A;</t><c><![CDATA[a]]]]><![CDATA[>b]]></c></r>`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		out := &bytes.Buffer{}
+		err := main.Delete(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, &bytes.Buffer{}, `//d`, nil, d.config)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+
+	// changed values are escaped even with EscapePreserve
+	out := &bytes.Buffer{}
+	err := main.Replace(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, &bytes.Buffer{}, `//t`, nil, "x\r\ny", "", main.TargetText, main.OutputConfig{EmptyElement: true, Escape: main.EscapePreserve})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), xmlpi+`<r a="1&#10;2&#9;'&quot;" b='&apos;x&apos;'><t>x&#xD;
+y</t><c><![CDATA[a]]]]><![CDATA[>b]]></c><d/></r>`)
+}
+
+func TestEscapePreserveAttrReference(t *testing.T) {
+	// a reference is decoded as XML, not HTML (&#x80; is U+0080, not "€")
+	const src = xmlpi + `<r a="&#x80;" b="&#x80;"/>`
+
+	out := &bytes.Buffer{}
+	err := main.Replace(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, &bytes.Buffer{}, `//@a`, nil, "€", "", main.TargetText, main.OutputConfig{EmptyElement: true, Escape: main.EscapePreserve})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), xmlpi+`<r a="€" b="&#x80;"/>`)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/antchfx/xpath"
+)
+
+type fmtCmd struct {
+	_ struct{} `help:"eksemel fmt --check *.xml" usage:"eksemel fmt [FILE...]  (stdin if omitted)"`
+
+	Indent       int    `cli:"indent=NUMBER" default:"4" help:"spaces per level (columns of a tab with --tabs)"`
+	Tabs         bool   `cli:"tabs" help:"indent with a tab per level"`
+	EmptyElement bool   `cli:"empty" default:"true"`
+	AttrOrder    string `cli:"attr-order" type:"Choice" choices:"preserve,alphabetical" default:"preserve" help:"order of attributes, preserve or alphabetical"`
+	MaxWidth     int    `cli:"max-width=NUMBER" help:"put attributes of a longer start tag on separate lines, aligned with the first one (0: never)"`
+	BlankLines   bool   `cli:"keep-blank-lines" help:"keep blank lines between children of the root element"`
+
+	Escape        string   `cli:"escape" type:"Choice" choices:"minimal,ascii,preserve" default:"minimal" help:"escaping of texts and attribute values, minimal, ascii (non-ASCII characters as references) or preserve (as in the input)"`
+	Quote         string   `cli:"quote" type:"Choice" choices:"double,single" default:"double" help:"quote of attribute values"`
+	PreserveSpace []string `cli:"preserve-space=XPATH" type:"List" help:"write the content of matched elements as it is, like xml:space=preserve (repeatable)"`
+
+	OutputEncoding string `cli:"output-encoding=ENCODING" type:"Encoding" help:"write in ENCODING updating the XML declaration (default: the encoding of the input)"`
+	BOM            bool   `cli:"bom" help:"write a BOM in UTF-8 (a BOM of the input is kept without it)"`
+	EOL            string `cli:"eol" type:"Choice" choices:"lf,crlf,preserve" default:"preserve" help:"line endings, lf, crlf or preserve (as the first line of the input)"`
+
+	Check bool `cli:"check" help:"write nothing, list files not formatted and exit with 1 if any"`
+	Diff  bool `cli:"diff" help:"write nothing, print unified diffs of files not formatted"`
+
+	InPlace bool   `cli:"in-place" help:"overwrite files not formatted (--in-place=SUFFIX keeps the originals as FILE+SUFFIX)"`
+	Backup  string `cli:"backup=SUFFIX" help:"with --in-place, keep the original as FILE+SUFFIX"`
+}
+
+// Format parses input and writes it formatted by config.
+func Format(input io.ReadCloser, output io.Writer, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func (c fmtCmd) Before() error {
+	if (c.Check || c.Diff) && (c.InPlace || c.Backup != "") {
+		return errors.New("--check and --diff are exclusive with --in-place")
+	}
+	for _, expr := range c.PreserveSpace {
+		if _, err := xpath.Compile(expr); err != nil {
+			return fmt.Errorf("preserve-space: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c fmtCmd) outputConfig() OutputConfig {
+	indent := strings.Repeat(" ", max(c.Indent, 0))
+	if c.Tabs {
+		indent = "\t"
+	}
+	return OutputConfig{
+		Indent:         indent,
+		EmptyElement:   c.EmptyElement,
+		Encoding:       c.OutputEncoding,
+		BOM:            c.BOM,
+		EOL:            c.EOL,
+		Escape:         c.Escape,
+		Quote:          c.Quote,
+		PreserveSpace:  c.PreserveSpace,
+		AttrOrder:      c.AttrOrder,
+		MaxWidth:       c.MaxWidth,
+		TabWidth:       c.Indent,
+		KeepBlankLines: c.BlankLines,
+	}
+}
+
+// ErrUnformatted is the result of fmt --check if any file is not formatted.
+var ErrUnformatted = errors.New("not formatted")
+
+// Run exits with 1 if any file is not formatted, and 2 on errors with --check.
+func (c fmtCmd) Run(args []string) error {
+	err := c.format(args)
+	if c.Check {
+		exitWithResult(err, ErrUnformatted)
+	}
+	return err
+}
+
+func (c fmtCmd) format(args []string) error {
+	if len(args) == 0 {
+		if c.InPlace || c.Backup != "" {
+			return errors.New("--in-place requires an input file, not stdin")
+		}
+		args = []string{""}
+	}
+
+	var failed, unformatted bool
+	for _, filename := range args {
+		changed, err := c.formatFile(filename, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", displayName(filename), err)
+			failed = true
+			continue
+		}
+		unformatted = unformatted || changed
+	}
+
+	if failed {
+		return errors.New("some files could not be formatted")
+	}
+	if c.Check && unformatted {
+		return ErrUnformatted
+	}
+	return nil
+}
+
+// formatFile formats the file filename (stdin if empty), and writes the result,
+// the name with --check or differences with --diff to output.
+// changed is true if the file is not formatted.
+func (c fmtCmd) formatFile(filename string, output io.Writer) (changed bool, err error) {
+	var src []byte
+	if filename == "" {
+		input, _, err := openInput(nil)
+		if err != nil {
+			return false, err
+		}
+		src, err = io.ReadAll(input)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		src, err = os.ReadFile(filename)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var formatted bytes.Buffer
+	if err := Format(NewFakeCloseReader(bytes.NewReader(src)), &formatted, c.outputConfig()); err != nil {
+		return false, err
+	}
+	changed = !bytes.Equal(src, formatted.Bytes())
+
+	switch {
+	case c.Check || c.Diff:
+		if changed && c.Check {
+			fmt.Fprintln(output, displayName(filename))
+		}
+		if changed && c.Diff {
+			UnifiedDiff(output, src, formatted.Bytes(), "a/"+displayName(filename), "b/"+displayName(filename))
+		}
+
+	case c.InPlace || c.Backup != "":
+		if changed {
+			err = WriteInPlace(filename, c.Backup, func(w io.Writer) error {
+				_, err := w.Write(formatted.Bytes())
+				return err
+			})
+		}
+
+	default:
+		_, err = output.Write(formatted.Bytes())
+	}
+	return changed, err
+}
+
+func displayName(filename string) string {
+	if filename == "" {
+		return "<stdin>"
+	}
+	return filename
+}
+
+// diffContext is the number of unchanged lines around changes in UnifiedDiff.
+const diffContext = 3
+
+// lineOp is a line of an edit script, kept (' '), removed ('-') or added ('+').
+type lineOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff writes line differences from a to b in the unified format.
+// Nothing is written if they are the same.
+func UnifiedDiff(w io.Writer, a, b []byte, aName, bName string) {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// line numbers (0-based) of a and b before each op
+	apos := make([]int, len(ops)+1)
+	bpos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		apos[i+1], bpos[i+1] = apos[i], bpos[i]
+		if op.kind != '+' {
+			apos[i+1]++
+		}
+		if op.kind != '-' {
+			bpos[i+1]++
+		}
+	}
+
+	header := false
+	for i := 0; ; {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			return
+		}
+
+		// a hunk continues over a gap of up to 2*diffContext unchanged lines
+		start, end := max(i-diffContext, 0), i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(ops) && ops[j].kind == ' ' {
+				j++
+			}
+			if j == len(ops) || j-end > 2*diffContext {
+				end = min(end+diffContext, j)
+				break
+			}
+			end = j
+		}
+
+		if !header {
+			fmt.Fprintf(w, "--- %s\n+++ %s\n", aName, bName)
+			header = true
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(apos[start], apos[end]-apos[start]), hunkRange(bpos[start], bpos[end]-bpos[start]))
+		for _, op := range ops[start:end] {
+			w.Write([]byte{op.kind})
+			io.WriteString(w, op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				io.WriteString(w, "\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+}
+
+// hunkRange returns a range of a hunk header from the 0-based start and the number of lines.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s after each line feed.
+func splitLines(s []byte) []string {
+	lines := strings.SplitAfter(string(s), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b (Myers' algorithm in linear space).
+func diffLines(a, b []string) []lineOp {
+	var ops []lineOp
+
+	var diff func(a, b []string)
+	diff = func(a, b []string) {
+		prefix := 0
+		for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+			ops = append(ops, lineOp{' ', a[prefix]})
+			prefix++
+		}
+		a, b = a[prefix:], b[prefix:]
+
+		suffix := 0
+		for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+			suffix++
+		}
+		common := a[len(a)-suffix:]
+		a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+		switch {
+		case len(a) == 0:
+			for _, line := range b {
+				ops = append(ops, lineOp{'+', line})
+			}
+		case len(b) == 0:
+			for _, line := range a {
+				ops = append(ops, lineOp{'-', line})
+			}
+		default:
+			// both ends differ, so that (x, y) splits them into smaller ones
+			x, y := middleSnake(a, b)
+			diff(a[:x], b[:y])
+			diff(a[x:], b[y:])
+		}
+
+		for _, line := range common {
+			ops = append(ops, lineOp{' ', line})
+		}
+	}
+	diff(a, b)
+
+	return ops
+}
+
+// middleSnake returns the start of the middle snake of the shortest edit script from a to b,
+// searching from both ends.
+func middleSnake(a, b []string) (x, y int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	offset := n + m + 1
+	forward := make([]int, 2*offset+1)  // the furthest x on each diagonal k = x-y
+	backward := make([]int, 2*offset+1) // the furthest x from the end on each diagonal from the end
+
+	for d := 0; d <= (n+m+1)/2; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y = x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			if kb := delta - k; delta%2 != 0 && -(d-1) <= kb && kb <= d-1 && x+backward[offset+kb] >= n {
+				return x0, y0
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			if k == -d || k != d && backward[offset+k-1] < backward[offset+k+1] {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y = x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if kf := delta - k; delta%2 == 0 && -d <= kf && kf <= d && forward[offset+kf]+x >= n {
+				return n - x, m - y
+			}
+		}
+	}
+
+	return n, m // not reached
+}
@@ -0,0 +1,152 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestFormat(t *testing.T) {
+	const src = xmlpi + `
+<project>
+  <!-- deps -->
+  <dependency groupId="org.example" artifactId="library" version="1.2.3"/>
+
+
+  <ns:build ns:z="1" a="2" xmlns:ns="urn:x"><plugin/></ns:build>
+</project>`
+
+	data := []struct {
+		config main.OutputConfig
+		out    string
+	}{
+		{
+			config: main.OutputConfig{Indent: "  ", EmptyElement: true},
+			out: xmlpi + `
+<project>
+  <!-- deps -->
+  <dependency groupId="org.example" artifactId="library" version="1.2.3"/>
+  <ns:build ns:z="1" a="2" xmlns:ns="urn:x">
+    <plugin/>
+  </ns:build>
+</project>
+`,
+		},
+		{
+			config: main.OutputConfig{Indent: "\t", EmptyElement: true, AttrOrder: main.AttrOrderAlphabetical, KeepBlankLines: true},
+			out: xmlpi + `
+<project>
+	<!-- deps -->
+	<dependency artifactId="library" groupId="org.example" version="1.2.3"/>
+
+	<ns:build a="2" ns:z="1" xmlns:ns="urn:x">
+		<plugin/>
+	</ns:build>
+</project>
+`,
+		},
+		{
+			config: main.OutputConfig{Indent: "  ", EmptyElement: true, MaxWidth: 60},
+			out: xmlpi + `
+<project>
+  <!-- deps -->
+  <dependency groupId="org.example"
+              artifactId="library"
+              version="1.2.3"/>
+  <ns:build ns:z="1" a="2" xmlns:ns="urn:x">
+    <plugin/>
+  </ns:build>
+</project>
+`,
+		},
+		{
+			// a tab counts 8 columns: 8+72 > 75
+			config: main.OutputConfig{Indent: "\t", EmptyElement: true, MaxWidth: 75},
+			out: xmlpi + `
+<project>
+	<!-- deps -->
+	<dependency groupId="org.example"
+	            artifactId="library"
+	            version="1.2.3"/>
+	<ns:build ns:z="1" a="2" xmlns:ns="urn:x">
+		<plugin/>
+	</ns:build>
+</project>
+`,
+		},
+		{
+			config: main.OutputConfig{Indent: "  ", EmptyElement: true, MaxWidth: 30},
+			out: xmlpi + `
+<project>
+  <!-- deps -->
+  <dependency groupId="org.example"
+              artifactId="library"
+              version="1.2.3"/>
+  <ns:build ns:z="1"
+            a="2"
+            xmlns:ns="urn:x">
+    <plugin/>
+  </ns:build>
+</project>
+`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		out := &bytes.Buffer{}
+		err := main.Format(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, d.config)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	data := []struct {
+		a, b string
+		out  string
+	}{
+		{
+			a:   "a\nb\n",
+			b:   "a\nb\n",
+			out: ``,
+		},
+		{
+			a: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n",
+			b: "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n15\n16\n",
+			out: "--- a/x.xml\n+++ b/x.xml\n" +
+				"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+				"@@ -11,5 +11,5 @@\n 11\n 12\n 13\n-14\n 15\n+16\n",
+		},
+		{
+			a:   "<a/>",
+			b:   "<a/>\n",
+			out: "--- a/x.xml\n+++ b/x.xml\n@@ -1 +1 @@\n-<a/>\n\\ No newline at end of file\n+<a/>\n",
+		},
+		{
+			a:   "",
+			b:   "<a/>\n",
+			out: "--- a/x.xml\n+++ b/x.xml\n@@ -0,0 +1 @@\n+<a/>\n",
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		out := &bytes.Buffer{}
+		main.UnifiedDiff(out, []byte(d.a), []byte(d.b), "a/x.xml", "b/x.xml")
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestFormatExitCode(t *testing.T) {
+	// fmt --check exits with 1 if not formatted, and 2 on errors
+	gotwant.Test(t, main.ExitCode(main.ErrUnformatted, main.ErrUnformatted), 1)
+
+	err := main.Format(main.NewFakeCloseReader(bytes.NewBufferString(xmlpi+`<root>`)), &bytes.Buffer{}, main.OutputConfig{})
+	gotwant.Test(t, main.ExitCode(err, main.ErrUnformatted), 2)
+}
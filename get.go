@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+type getCmd struct {
+	_ struct{} `help:"eksemel get --xpath //*  hoge.xml"`
+
+	XPath string `cli:"xpath" required:"true"`
+
+	Multiple  bool
+	Separator string `cli:"separator,sep" type:"Separator" default:"\n"`
+
+	Format string `cli:"format=FORMAT" type:"Choice" choices:"raw,text,outer-xml,inner-xml,json,csv,tsv" default:"raw" help:"raw, text, outer-xml, inner-xml, json, csv or tsv"`
+
+	Columns []string `cli:"column=COLUMN" type:"List" help:"name=xpath relative to each node, as a column of csv, tsv or json (repeatable)"`
+
+	ExitStatus bool `cli:"exit-status" help:"exit with 1 if the result is false, 0, empty or no nodes (ignored in run scripts)"`
+
+	Namespaces []string `cli:"ns=BINDING" type:"List" help:"bind a namespace prefix=uri for XPath (repeatable)"`
+
+	DTDDefaults bool `cli:"dtd-defaults" help:"add default attribute values declared in DOCTYPE"`
+
+	C14N string `cli:"c14n=MODE" type:"C14N" help:"write outer-xml and inner-xml as canonical XML (inclusive or exclusive, optionally with ,with-comments)"`
+
+	Stream bool `cli:"stream" help:"read element by element, for a large input and a streamable XPath (ignored in run scripts)"`
+
+	// uncommon
+	Indent       int  `cli:"indent=NUMBER" default:"0"`
+	EmptyElement bool `cli:"empty" default:"true"`
+}
+
+// outputConfig returns the config for the input file filename (empty for stdin).
+func (c getCmd) outputConfig(filename string) OutputConfig {
+	config := OutputConfig{Indent: strings.Repeat(" ", max(c.Indent, 0)), EmptyElement: c.EmptyElement, DTDDefaults: c.DTDDefaults}
+	if filename != "" {
+		config.InputDir = filepath.Dir(filename)
+	}
+	config.C14N, config.C14NComments, _ = ParseC14N(c.C14N)
+	return config
+}
+
+// ErrFalse is returned by Get with exitStatus if the result is false.
+var ErrFalse = errors.New("false")
+
+// Get writes nodes matched by xpath in format.
+//
+//   - raw: names of elements and attributes, and values of the other nodes
+//   - text: string values (texts of descendants for an element)
+//   - outer-xml, inner-xml: the node and its descendants, or only descendants
+//   - json: an array of objects {"name", "attributes", "text", "path"}
+//   - csv, tsv: rows of path, name and text, with a header
+//
+// columns (name=xpath) replace the fields of json, csv and tsv.
+// Each xpath is evaluated with a node as the context, and results in the string value of the first node.
+//
+// Unless multiple, only the first node is written.
+//
+// If xpath results in a number, a string or a boolean (e.g. count(//a)), it is written as it is,
+// or as a JSON value, or as a csv/tsv row with a header "value".
+//
+// With exitStatus, ErrFalse is returned after writing if the result is false in terms of boolean() of XPath.
+func Get(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, multiple bool, sep, format string, columns []string, exitStatus bool, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	return getNodes(doc, output, xpath, ns, multiple, sep, format, columns, exitStatus, config)
+}
+
+func getNodes(doc *xmlquery.Node, output io.Writer, expr string, ns map[string]string, multiple bool, sep, format string, columns []string, exitStatus bool, config OutputConfig) error {
+	exp, err := compileXPath(doc, expr, ns)
+	if err != nil {
+		return fmt.Errorf("xpath: %v\n", err)
+	}
+
+	result := exp.Evaluate(xmlquery.CreateXPathNavigator(doc))
+	if _, ok := result.(*xpath.NodeIterator); !ok {
+		if len(columns) > 0 {
+			return errors.New("--column requires an XPath to nodes")
+		}
+		if err := writeValue(output, result, format); err != nil {
+			return err
+		}
+		if exitStatus && !xpathBoolean(result) {
+			return ErrFalse
+		}
+		return nil
+	}
+
+	nodes := selectNodes(doc, exp)
+	if err := writeNodes(doc, output, nodes, ns, multiple, sep, format, columns, config); err != nil {
+		return err
+	}
+	if exitStatus && len(nodes) == 0 {
+		return ErrFalse
+	}
+	return nil
+}
+
+func writeNodes(doc *xmlquery.Node, output io.Writer, nodes []*xmlquery.Node, ns map[string]string, multiple bool, sep, format string, columns []string, config OutputConfig) error {
+	if !multiple && len(nodes) > 1 {
+		nodes = nodes[:1]
+	}
+
+	if len(columns) > 0 {
+		return getColumns(doc, output, nodes, ns, format, columns, config)
+	}
+
+	switch format {
+	case "", "raw", "text", "outer-xml", "inner-xml":
+		data := ""
+		for i, n := range nodes {
+			if i > 0 {
+				data += sep
+			}
+			data += nodeString(n, format, config)
+		}
+		fmt.Fprintln(output, data)
+
+	case "json":
+		list := make([]jsonNode, 0, len(nodes))
+		for _, n := range nodes {
+			list = append(list, jsonNode{
+				Name:       nodeName(n),
+				Attributes: nodeAttrs(n),
+				Text:       nodeText(n),
+				Path:       nodePath(n),
+			})
+		}
+		enc := json.NewEncoder(output)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", config.Indent)
+		return enc.Encode(list)
+
+	case "csv", "tsv":
+		w := csv.NewWriter(output)
+		if format == "tsv" {
+			w.Comma = '\t'
+		}
+		w.Write([]string{"path", "name", "text"})
+		for _, n := range nodes {
+			w.Write([]string{nodePath(n), nodeName(n), nodeText(n)})
+		}
+		w.Flush()
+		return w.Error()
+
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	return nil
+}
+
+func writeValue(output io.Writer, value interface{}, format string) error {
+	s := valueString(value)
+
+	switch format {
+	case "", "raw", "text", "outer-xml", "inner-xml":
+		_, err := fmt.Fprintln(output, s)
+		return err
+
+	case "json":
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			value = s // not representable in JSON
+		}
+		enc := json.NewEncoder(output)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(value)
+
+	case "csv", "tsv":
+		w := csv.NewWriter(output)
+		if format == "tsv" {
+			w.Comma = '\t'
+		}
+		w.Write([]string{"value"})
+		w.Write([]string{s})
+		w.Flush()
+		return w.Error()
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// xpathBoolean converts value as boolean() of XPath.
+func xpathBoolean(value interface{}) bool {
+	switch v := value.(type) {
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return value != nil
+}
+
+type column struct {
+	name string
+	expr *xpath.Expr
+}
+
+func getColumns(doc *xmlquery.Node, output io.Writer, nodes []*xmlquery.Node, ns map[string]string, format string, columns []string, config OutputConfig) error {
+	cols := make([]column, 0, len(columns))
+	for _, c := range columns {
+		name, expr, found := strings.Cut(c, "=")
+		if !found || name == "" {
+			return fmt.Errorf("column: %q is not in the form of name=xpath", c)
+		}
+		exp, err := compileXPath(doc, expr, ns)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", name, err)
+		}
+		cols = append(cols, column{name: name, expr: exp})
+	}
+
+	switch format {
+	case "json":
+		// keep the order of columns
+		var b bytes.Buffer
+		b.WriteByte('[')
+		for i, n := range nodes {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('{')
+			for j, c := range cols {
+				if j > 0 {
+					b.WriteByte(',')
+				}
+				writeJSONString(&b, c.name)
+				b.WriteByte(':')
+				writeJSONString(&b, evaluateString(n, c.expr))
+			}
+			b.WriteByte('}')
+		}
+		b.WriteByte(']')
+
+		if config.Indent != "" {
+			var indented bytes.Buffer
+			json.Indent(&indented, b.Bytes(), "", config.Indent)
+			b = indented
+		}
+		b.WriteByte('\n')
+		_, err := b.WriteTo(output)
+		return err
+
+	case "csv", "tsv":
+		w := csv.NewWriter(output)
+		if format == "tsv" {
+			w.Comma = '\t'
+		}
+		header := make([]string, 0, len(cols))
+		for _, c := range cols {
+			header = append(header, c.name)
+		}
+		w.Write(header)
+		for _, n := range nodes {
+			row := make([]string, 0, len(cols))
+			for _, c := range cols {
+				row = append(row, evaluateString(n, c.expr))
+			}
+			w.Write(row)
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	return errors.New("--column requires --format csv, tsv or json")
+}
+
+// evaluateString evaluates expr with n as the context node (and the root), and returns the result as a string.
+// A node-set results in the string value of the first node.
+func evaluateString(n *xmlquery.Node, expr *xpath.Expr) string {
+	switch v := expr.Evaluate(xmlquery.CreateXPathNavigator(n)).(type) {
+	case *xpath.NodeIterator:
+		if v.MoveNext() {
+			return v.Current().Value()
+		}
+		return ""
+	default:
+		return valueString(v)
+	}
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	b.Truncate(b.Len() - 1) // newline by Encode
+}
+
+type jsonNode struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes"`
+	Text       string            `json:"text"`
+	Path       string            `json:"path"`
+}
+
+func nodeString(n *xmlquery.Node, format string, config OutputConfig) string {
+	switch format {
+	case "text":
+		return nodeText(n)
+
+	case "outer-xml", "inner-xml":
+		if n.Type == xmlquery.AttributeNode {
+			return n.InnerText()
+		}
+
+		var b bytes.Buffer
+		if format == "outer-xml" || n.Type == xmlquery.DocumentNode {
+			OutputXML(&b, n, config)
+		} else {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				OutputXML(&b, c, config)
+			}
+		}
+		return strings.TrimSuffix(b.String(), "\n")
+
+	default:
+		return n.Data
+	}
+}
+
+// nodeText returns the string value of n.
+func nodeText(n *xmlquery.Node) string {
+	if n.Type == xmlquery.CommentNode {
+		return n.Data
+	}
+	return n.InnerText()
+}
+
+// nodeName returns a name of n as --name of add command.
+func nodeName(n *xmlquery.Node) string {
+	switch n.Type {
+	case xmlquery.DocumentNode:
+		return "#document"
+	case xmlquery.TextNode:
+		return "#text"
+	case xmlquery.CharDataNode:
+		return "#cdata-section"
+	case xmlquery.CommentNode:
+		return "#comment"
+	case xmlquery.AttributeNode:
+		if n.Prefix != "" {
+			return "@" + n.Prefix + ":" + n.Data
+		}
+		return "@" + n.Data
+	}
+
+	if n.Prefix != "" {
+		return n.Prefix + ":" + n.Data
+	}
+	return n.Data
+}
+
+func nodeAttrs(n *xmlquery.Node) map[string]string {
+	attrs := make(map[string]string)
+	if n.Type != xmlquery.ElementNode {
+		return attrs
+	}
+
+	for _, attr := range n.Attr {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		attrs[name] = attr.Value
+	}
+	return attrs
+}
+
+// nodePath returns an absolute XPath to n.
+// A position predicate is added only if n has siblings of the same name.
+func nodePath(n *xmlquery.Node) string {
+	if n.Parent == nil {
+		return "/"
+	}
+
+	var step string
+	switch n.Type {
+	case xmlquery.AttributeNode:
+		step = nodeName(n)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		step = "text()"
+	case xmlquery.CommentNode:
+		step = "comment()"
+	case xmlquery.DeclarationNode:
+		step = "processing-instruction('" + n.Data + "')"
+	default:
+		step = nodeName(n)
+	}
+
+	if n.Type != xmlquery.AttributeNode {
+		pos, count := 0, 0
+		for s := n.Parent.FirstChild; s != nil; s = s.NextSibling {
+			// as the navigator of xmlquery, whitespace-only texts other than the first child are not counted
+			if s != n.Parent.FirstChild && isWhitespaceText(s) && s != n {
+				continue
+			}
+			if sameStep(s, n) {
+				count++
+				if s == n {
+					pos = count
+				}
+			}
+		}
+		if count > 1 {
+			step += "[" + strconv.Itoa(pos) + "]"
+		}
+	}
+
+	parent := nodePath(n.Parent)
+	if parent == "/" {
+		return "/" + step
+	}
+	return parent + "/" + step
+}
+
+func sameStep(a, b *xmlquery.Node) bool {
+	switch b.Type {
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return a.Type == xmlquery.TextNode || a.Type == xmlquery.CharDataNode
+	case xmlquery.ElementNode:
+		return a.Type == b.Type && a.Prefix == b.Prefix && a.Data == b.Data
+	}
+	return a.Type == b.Type && a.Data == b.Data
+}
+
+func (c getCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	return getNodes(doc, output, c.XPath, mergeNamespaces(ns, cns), c.Multiple, c.Separator, c.Format, c.Columns, false, c.outputConfig(""))
+}
+
+// Run exits with 1 if the result is false, and 2 on errors with --exit-status.
+func (c getCmd) Run(args []string) error {
+	err := c.get(args)
+	if c.ExitStatus {
+		exitWithResult(err, ErrFalse)
+	}
+	return err
+}
+
+func (c getCmd) get(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	if c.Stream {
+		if len(c.Columns) > 0 {
+			return errors.New("--column is not available with --stream")
+		}
+		if c.DTDDefaults {
+			return errors.New("--dtd-defaults is not available with --stream")
+		}
+		err = StreamGet(input, os.Stdout, c.XPath, ns, c.Multiple, c.Separator, c.Format, c.ExitStatus, c.outputConfig(filename))
+	} else {
+		err = Get(input, os.Stdout, os.Stderr, c.XPath, ns, c.Multiple, c.Separator, c.Format, c.Columns, c.ExitStatus, c.outputConfig(filename))
+	}
+	return err
+}
@@ -0,0 +1,264 @@
+package main_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type gettestdata struct {
+	input    string
+	xpath    string
+	multiple bool
+	sep      string
+	format   string
+	columns  []string
+	exit     bool
+
+	indent int
+
+	err         error
+	out, errout string
+}
+
+func testget(t *testing.T, data []gettestdata) {
+	t.Helper()
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+		sep := d.sep
+		if sep == "" {
+			sep = "\n"
+		}
+		err := main.Get(
+			main.NewFakeCloseReader(in),
+			out,
+			errout,
+			d.xpath,
+			nil,
+			d.multiple,
+			sep,
+			d.format,
+			d.columns,
+			d.exit,
+			main.OutputConfig{Indent: strings.Repeat(" ", d.indent), EmptyElement: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), d.errout, gotwant.Desc(seq))
+	}
+}
+
+const getinput = xmlpi + `<root><hoge a="1" b="x&amp;y">text1<fuga>text2</fuga></hoge><hoge a="2"/><!--comment--></root>`
+
+func TestGet(t *testing.T) {
+	testget(t, []gettestdata{
+		{
+			input: getinput,
+			xpath: `//hoge`,
+			out:   "hoge\n",
+		},
+		{
+			input:    getinput,
+			xpath:    `//hoge/@a`,
+			multiple: true,
+			sep:      ",",
+			out:      "a,a\n",
+		},
+		{
+			input:    getinput,
+			xpath:    `//hoge`,
+			multiple: true,
+			format:   "text",
+			out:      "text1text2\n\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `//hoge`,
+			format: "outer-xml",
+			out:    `<hoge a="1" b="x&amp;y">text1<fuga>text2</fuga></hoge>` + "\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `//hoge`,
+			format: "outer-xml",
+			indent: 1,
+			out:    `<hoge a="1" b="x&amp;y">text1<fuga>text2</fuga></hoge>` + "\n", // mixed content
+		},
+		{
+			input:  getinput,
+			xpath:  `//hoge`,
+			format: "inner-xml",
+			out:    `text1<fuga>text2</fuga>` + "\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `//hoge/@b`,
+			format: "outer-xml",
+			out:    "x&y\n",
+		},
+		{
+			input:    getinput,
+			xpath:    `//hoge | //comment() | //fuga/text() | //hoge/@a`,
+			multiple: true,
+			format:   "json",
+			out: `[{"name":"hoge","attributes":{"a":"1","b":"x&y"},"text":"text1text2","path":"/root/hoge[1]"},` +
+				`{"name":"hoge","attributes":{"a":"2"},"text":"","path":"/root/hoge[2]"},` +
+				`{"name":"#comment","attributes":{},"text":"comment","path":"/root/comment()"},` +
+				`{"name":"#text","attributes":{},"text":"text2","path":"/root/hoge[1]/fuga/text()"},` +
+				`{"name":"@a","attributes":{},"text":"1","path":"/root/hoge[1]/@a"},` +
+				`{"name":"@a","attributes":{},"text":"2","path":"/root/hoge[2]/@a"}]` + "\n",
+		},
+		{
+			input:    xmlpi + `<root xmlns:x="urn:x"><a id="1" x:id="2"/></root>`,
+			xpath:    `//@x:id`,
+			multiple: true,
+			format:   "csv",
+			out:      "path,name,text\n/root/a/@x:id,@x:id,2\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `//nothing`,
+			format: "json",
+			out:    "[]\n",
+		},
+		{
+			input:    xmlpi + `<root><a>x,"y"</a><a>tab	tab</a></root>`,
+			xpath:    `//a`,
+			multiple: true,
+			format:   "csv",
+			out:      "path,name,text\n/root/a[1],a,\"x,\"\"y\"\"\"\n/root/a[2],a,tab\ttab\n",
+		},
+		{
+			input:    xmlpi + `<root><a>x,"y"</a><a>tab	tab</a></root>`,
+			xpath:    `//a`,
+			multiple: true,
+			format:   "tsv",
+			out:      "path\tname\ttext\n/root/a[1]\ta\t\"x,\"\"y\"\"\"\n/root/a[2]\ta\t\"tab\ttab\"\n",
+		},
+		{
+			input:    getinput,
+			xpath:    `//hoge`,
+			multiple: true,
+			format:   "csv",
+			columns:  []string{"a=@a", "fuga=fuga", "count=count(*)", "none=@none", "concat=concat(@a, ',', @b)"},
+			out:      "a,fuga,count,none,concat\n1,text2,1,,\"1,x&y\"\n2,,0,,\"2,\"\n",
+		},
+		{
+			input:    getinput,
+			xpath:    `//hoge`,
+			multiple: true,
+			format:   "json",
+			columns:  []string{"z=@a", "a=name(..)"},
+			out:      `[{"z":"1","a":"root"},{"z":"2","a":"root"}]` + "\n",
+		},
+		{
+			input:   getinput,
+			xpath:   `//hoge`,
+			format:  "json",
+			columns: []string{"a=@a"},
+			indent:  1,
+			out:     "[\n {\n  \"a\": \"1\"\n }\n]\n",
+		},
+		{
+			input:   getinput,
+			xpath:   `//hoge`,
+			format:  "raw",
+			columns: []string{"a=@a"},
+			err:     errors.New("--column requires --format csv, tsv or json"),
+		},
+		{
+			input:   getinput,
+			xpath:   `//hoge`,
+			format:  "csv",
+			columns: []string{"@a"},
+			err:     errors.New(`column: "@a" is not in the form of name=xpath`),
+		},
+		{
+			input: getinput,
+			xpath: `count(//hoge)`,
+			out:   "2\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `sum(//hoge/@a) div 4`,
+			format: "json",
+			out:    "0.75\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `number(//fuga)`,
+			format: "json",
+			out:    "\"NaN\"\n",
+		},
+		{
+			input:  getinput,
+			xpath:  `concat(//fuga, ':', //hoge/@b)`,
+			format: "csv",
+			out:    "value\ntext2:x&y\n",
+		},
+		{
+			input: getinput,
+			xpath: `boolean(//comment())`,
+			exit:  true,
+			out:   "true\n",
+		},
+		{
+			input: getinput,
+			xpath: `boolean(//nothing)`,
+			exit:  true,
+			out:   "false\n",
+			err:   main.ErrFalse,
+		},
+		{
+			input: getinput,
+			xpath: `//nothing`,
+			exit:  true,
+			out:   "\n",
+			err:   main.ErrFalse,
+		},
+		{
+			input: getinput,
+			xpath: `count(//nothing)`,
+			exit:  true,
+			out:   "0\n",
+			err:   main.ErrFalse,
+		},
+		{
+			input:   getinput,
+			xpath:   `count(//hoge)`,
+			format:  "csv",
+			columns: []string{"a=@a"},
+			err:     errors.New("--column requires an XPath to nodes"),
+		},
+	})
+}
+
+func TestGetExitCode(t *testing.T) {
+	data := []struct {
+		input string
+		xpath string
+		code  int
+	}{
+		{input: getinput, xpath: `boolean(//comment())`, code: 0},
+		{input: getinput, xpath: `boolean(//nothing)`, code: 1},
+		{input: getinput, xpath: `//nothing`, code: 1},
+		{input: getinput, xpath: `//hoge[`, code: 2},
+		{input: xmlpi + `<root>`, xpath: `//nothing`, code: 2},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		in, out, errout := prepare(d.input)
+		err := main.Get(main.NewFakeCloseReader(in), out, errout, d.xpath, nil, false, "\n", "raw", nil, true, main.OutputConfig{})
+		gotwant.Test(t, main.ExitCode(err, main.ErrFalse), d.code, gotwant.Desc(seq))
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandInPlace rewrites --in-place=SUFFIX in args into --in-place --backup SUFFIX,
+// as options of gli take a value always or never.
+func ExpandInPlace(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for i, a := range args {
+		if a == "--" {
+			return append(expanded, args[i:]...)
+		}
+		if suffix, found := strings.CutPrefix(a, "--in-place="); found {
+			expanded = append(expanded, "--in-place", "--backup", suffix)
+			continue
+		}
+		expanded = append(expanded, a)
+	}
+	return expanded
+}
+
+// WriteInPlace calls write with a temporary file next to filename, and then
+// replaces filename with it. A symbolic link is kept, and its target is replaced.
+// If backupSuffix is not empty, the original content is kept as filename+backupSuffix.
+func WriteInPlace(filename, backupSuffix string, write func(io.Writer) error) error {
+	target, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", filename)
+	}
+
+	return writeTemp(target, info.Mode().Perm(), write, func() error {
+		if backupSuffix == "" {
+			return nil
+		}
+		if err := copyFile(filename, filename+backupSuffix, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		return nil
+	})
+}
+
+// WriteFile calls write with a temporary file next to filename, and then
+// renames it to filename, so that filename may be the input.
+// filename is not touched if write fails. A symbolic link is kept, and its target is replaced.
+func WriteFile(filename string, write func(io.Writer) error) error {
+	perm := os.FileMode(0o644)
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	if info, err := os.Stat(filename); err == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", filename)
+		}
+		perm = info.Mode().Perm()
+	}
+
+	return writeTemp(filename, perm, write, nil)
+}
+
+// writeTemp calls write with a temporary file next to filename, and renames it to filename after beforeRename.
+func writeTemp(filename string, perm os.FileMode, write func(io.Writer) error, beforeRename func() error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	tmpname := tmp.Name()
+	defer os.Remove(tmpname) // no-op after the rename
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if beforeRename != nil {
+		if err := beforeRename(); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmpname, filename)
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
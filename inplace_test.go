@@ -0,0 +1,148 @@
+package main_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestWriteInPlace(t *testing.T) {
+	t.Run("Replace", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "a.xml")
+		os.WriteFile(name, []byte(xmlpi+`<root><hoge/></root>`), 0600)
+
+		err := main.WriteInPlace(name, "", func(w io.Writer) error {
+			in, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			return main.Delete(in, w, io.Discard, `/root/hoge`, nil, main.OutputConfig{EmptyElement: true})
+		})
+		gotwant.TestError(t, err, nil)
+
+		content, _ := os.ReadFile(name)
+		gotwant.Test(t, string(content), xmlpi+`<root/>`)
+
+		_, err = os.Stat(name + ".bak")
+		gotwant.Test(t, os.IsNotExist(err), true)
+
+		entries, _ := os.ReadDir(dir)
+		gotwant.Test(t, len(entries), 1)
+
+		if runtime.GOOS != "windows" {
+			info, _ := os.Stat(name)
+			gotwant.Test(t, info.Mode().Perm(), os.FileMode(0600))
+		}
+	})
+
+	t.Run("Backup", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "a.xml")
+		os.WriteFile(name, []byte("before"), 0644)
+
+		err := main.WriteInPlace(name, ".bak", func(w io.Writer) error {
+			_, err := io.WriteString(w, "after")
+			return err
+		})
+		gotwant.TestError(t, err, nil)
+
+		content, _ := os.ReadFile(name)
+		gotwant.Test(t, string(content), "after")
+		content, _ = os.ReadFile(name + ".bak")
+		gotwant.Test(t, string(content), "before")
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "a.xml")
+		link := filepath.Join(dir, "link.xml")
+		os.WriteFile(name, []byte("before"), 0644)
+		if err := os.Symlink("a.xml", link); err != nil {
+			t.Skip(err)
+		}
+
+		err := main.WriteInPlace(link, ".bak", func(w io.Writer) error {
+			_, err := io.WriteString(w, "after")
+			return err
+		})
+		gotwant.TestError(t, err, nil)
+
+		info, _ := os.Lstat(link)
+		gotwant.Test(t, info.Mode()&os.ModeSymlink != 0, true)
+		content, _ := os.ReadFile(name)
+		gotwant.Test(t, string(content), "after")
+		content, _ = os.ReadFile(link + ".bak")
+		gotwant.Test(t, string(content), "before")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "a.xml")
+		os.WriteFile(name, []byte("before"), 0644)
+
+		werr := errors.New("failed")
+		err := main.WriteInPlace(name, ".bak", func(w io.Writer) error {
+			io.WriteString(w, "half")
+			return werr
+		})
+		gotwant.TestError(t, err, werr)
+
+		content, _ := os.ReadFile(name)
+		gotwant.Test(t, string(content), "before")
+
+		entries, _ := os.ReadDir(dir)
+		names := []string{}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		gotwant.Test(t, strings.Join(names, ","), "a.xml")
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.xml")
+
+	err := main.WriteFile(name, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	})
+	gotwant.TestError(t, err, nil)
+
+	content, _ := os.ReadFile(name)
+	gotwant.Test(t, string(content), "new")
+
+	werr := errors.New("failed")
+	err = main.WriteFile(name, func(w io.Writer) error {
+		io.WriteString(w, "half")
+		return werr
+	})
+	gotwant.TestError(t, err, werr)
+
+	content, _ = os.ReadFile(name)
+	gotwant.Test(t, string(content), "new")
+}
+
+func TestExpandInPlace(t *testing.T) {
+	data := []struct {
+		args []string
+		want []string
+	}{
+		{args: []string{"delete", "--in-place", "a.xml"}, want: []string{"delete", "--in-place", "a.xml"}},
+		{args: []string{"delete", "--in-place=.bak", "a.xml"}, want: []string{"delete", "--in-place", "--backup", ".bak", "a.xml"}},
+		{args: []string{"delete", "--", "--in-place=.bak"}, want: []string{"delete", "--", "--in-place=.bak"}},
+	}
+
+	for i, d := range data {
+		gotwant.Test(t, main.ExpandInPlace(d.args), d.want, gotwant.Desc(strconv.Itoa(i+1)))
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type toJSONCmd struct {
+	_ struct{} `help:"eksemel to-json hoge.xml > hoge.json"`
+
+	ForceArray []string `cli:"force-array=XPATH" type:"List" help:"make matched elements arrays even if single (repeatable)"`
+	Namespaces []string `cli:"ns=BINDING" type:"List" help:"bind a namespace prefix=uri for XPath (repeatable)"`
+
+	Indent int `cli:"indent=NUMBER" default:"2"`
+
+	textOutput
+}
+
+type fromJSONCmd struct {
+	_ struct{} `help:"eksemel from-json hoge.json > hoge.xml"`
+
+	common
+}
+
+// jsonObject is a JSON object keeping the order of its members.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	Key   string
+	Value any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := enc.Encode(m.Key); err != nil {
+			return nil, err
+		}
+		b.WriteByte(':')
+		if err := enc.Encode(m.Value); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// ToJSON writes input as JSON.
+//
+// An element is {"@attr": "value", "#text": "text", "child": ...},
+// or "text" if it has only a text, and repeated children are an array.
+// Elements matched by forceArray are always arrays.
+func ToJSON(input io.ReadCloser, output io.Writer, ns map[string]string, forceArray []string, indent string) error {
+	doc, err := parseInput(input, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+
+	c := &jsonConverter{arrays: make(map[*xmlquery.Node]bool)}
+	for _, expr := range forceArray {
+		nodes, err := queryAll(doc, expr, ns)
+		if err != nil {
+			return fmt.Errorf("force-array: %v", err)
+		}
+		for _, n := range nodes {
+			c.arrays[n] = true
+		}
+	}
+
+	nodes := prologNodes(doc)
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		nodes = append(nodes, n)
+	}
+
+	enc := json.NewEncoder(output)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	return enc.Encode(c.members(nil, nodes, false))
+}
+
+type jsonConverter struct {
+	arrays map[*xmlquery.Node]bool
+}
+
+func (c *jsonConverter) value(n *xmlquery.Node) any {
+	var obj jsonObject
+	for _, attr := range n.Attr {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		obj = append(obj, jsonMember{Key: "@" + name, Value: attr.Value})
+	}
+
+	var children []*xmlquery.Node
+	hasElem := false
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, child)
+		hasElem = hasElem || child.Type == xmlquery.ElementNode
+	}
+
+	obj = c.members(obj, children, hasElem)
+
+	switch {
+	case len(obj) == 0:
+		return ""
+	case len(obj) == 1 && obj[0].Key == "#text":
+		return obj[0].Value
+	}
+	return obj
+}
+
+// members appends members for nodes to obj.
+// Texts are concatenated, and trimmed if trim (indentation among elements).
+func (c *jsonConverter) members(obj jsonObject, nodes []*xmlquery.Node, trim bool) jsonObject {
+	index := make(map[string]int)
+
+	add := func(key string, v any, array bool) {
+		i, found := index[key]
+		if !found {
+			if array {
+				v = []any{v}
+			}
+			index[key] = len(obj)
+			obj = append(obj, jsonMember{Key: key, Value: v})
+			return
+		}
+
+		if values, ok := obj[i].Value.([]any); ok {
+			obj[i].Value = append(values, v)
+		} else {
+			obj[i].Value = []any{obj[i].Value, v}
+		}
+	}
+
+	var text strings.Builder
+	for _, n := range nodes {
+		switch n.Type {
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if isWhitespaceText(n) {
+				continue
+			}
+			if _, found := index["#text"]; !found {
+				add("#text", nil, false)
+			}
+			text.WriteString(n.Data)
+
+		case xmlquery.CommentNode:
+			add("#comment", n.Data, false)
+
+		case xmlquery.ElementNode:
+			add(nodeName(n), c.value(n), c.arrays[n])
+		}
+	}
+
+	if i, found := index["#text"]; found {
+		if trim {
+			obj[i].Value = strings.TrimSpace(text.String())
+		} else {
+			obj[i].Value = text.String()
+		}
+	}
+	if obj == nil {
+		obj = jsonObject{}
+	}
+	return obj
+}
+
+// FromJSON writes input JSON as XML, in the mapping of ToJSON.
+// The top level must be an object with a single element member.
+func FromJSON(input io.ReadCloser, output io.Writer, config OutputConfig) error {
+	dec := json.NewDecoder(input)
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	input.Close()
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+
+	top, ok := v.(jsonObject)
+	if !ok {
+		return errors.New("json: the top level must be an object")
+	}
+
+	doc, err := buildDocument(top)
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+
+	OutputXML(output, doc, config)
+	return nil
+}
+
+// buildDocument makes a document from top in the mapping of ToJSON.
+func buildDocument(top jsonObject) (*xmlquery.Node, error) {
+	doc := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	xmlquery.AddChild(doc, &xmlquery.Node{
+		Type: xmlquery.DeclarationNode,
+		Data: "xml",
+		Attr: []xmlquery.Attr{{Name: xml.Name{Local: "version"}, Value: "1.0"}},
+	})
+
+	roots := 0
+	for _, m := range top {
+		switch {
+		case m.Key == "#comment":
+			if err := appendJSONComments(doc, m.Value); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(m.Key, "@") || m.Key == "#text":
+			return nil, fmt.Errorf("%q is not allowed at the top level", m.Key)
+		default:
+			if _, ok := m.Value.([]any); ok {
+				return nil, fmt.Errorf("the root element %q must not be an array", m.Key)
+			}
+			if err := appendJSONElement(doc, m.Key, m.Value); err != nil {
+				return nil, err
+			}
+			roots++
+		}
+	}
+	if roots != 1 {
+		return nil, errors.New("a single root element is required")
+	}
+
+	return doc, nil
+}
+
+// decodeJSON decodes a value keeping the order of object members.
+func decodeJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{Key: key.(string), Value: v})
+		}
+		_, err := dec.Token()
+		return obj, err
+
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+
+	return tok, nil
+}
+
+func appendJSONElement(parent *xmlquery.Node, name string, v any) error {
+	if !reQName.MatchString(name) {
+		return fmt.Errorf("%q is not a valid element name", name)
+	}
+
+	n := &xmlquery.Node{Type: xmlquery.ElementNode, Data: name}
+	if prefix, local, found := strings.Cut(name, ":"); found {
+		n.Prefix, n.Data = prefix, local
+	}
+	xmlquery.AddChild(parent, n)
+
+	obj, ok := v.(jsonObject)
+	if !ok {
+		text, err := jsonScalar(name, v)
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			xmlquery.AddChild(n, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+		}
+		return nil
+	}
+
+	for _, m := range obj {
+		switch {
+		case strings.HasPrefix(m.Key, "@"):
+			if !reQName.MatchString(m.Key[1:]) {
+				return fmt.Errorf("%q is not a valid attribute name", m.Key)
+			}
+			value, err := jsonScalar(m.Key, m.Value)
+			if err != nil {
+				return err
+			}
+			setAttr(n, m.Key[1:], value)
+
+		case m.Key == "#text":
+			text, err := jsonScalar(m.Key, m.Value)
+			if err != nil {
+				return err
+			}
+			xmlquery.AddChild(n, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+
+		case m.Key == "#comment":
+			if err := appendJSONComments(n, m.Value); err != nil {
+				return err
+			}
+
+		default:
+			values, ok := m.Value.([]any)
+			if !ok {
+				values = []any{m.Value}
+			}
+			for _, item := range values {
+				if _, ok := item.([]any); ok {
+					return fmt.Errorf("nested array in %q", m.Key)
+				}
+				if err := appendJSONElement(n, m.Key, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func appendJSONComments(parent *xmlquery.Node, v any) error {
+	values, ok := v.([]any)
+	if !ok {
+		values = []any{v}
+	}
+	for _, item := range values {
+		text, err := jsonScalar("#comment", item)
+		if err != nil {
+			return err
+		}
+		xmlquery.AddChild(parent, &xmlquery.Node{Type: xmlquery.CommentNode, Data: text})
+	}
+	return nil
+}
+
+// jsonScalar returns a string, a number or a boolean as a text, and null as an empty text.
+func jsonScalar(key string, v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("%q must be a string, a number, a boolean or null", key)
+}
+
+func (c toJSONCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, _, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(func(w io.Writer) error {
+		return ToJSON(input, w, ns, c.ForceArray, strings.Repeat(" ", max(c.Indent, 0)))
+	})
+}
+
+func (c fromJSONCmd) Before() error {
+	return c.common.checkConverted()
+}
+
+func (c fromJSONCmd) Run(args []string) error {
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return FromJSON(input, w, c.outputConfig(filename))
+	})
+}
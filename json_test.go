@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestToJSON(t *testing.T) {
+	data := []struct {
+		input      string
+		forceArray []string
+		out        string
+	}{
+		{
+			input: xmlpi + `<root><a>text</a><b/></root>`,
+			out:   `{"root":{"a":"text","b":""}}`,
+		},
+		{
+			input: xmlpi + `<root id="1" x:y="2" xmlns:x="urn:x">
+    <item>1</item>
+    <!-- comment -->
+    <item n="2">two</item>
+    <x:z>&lt;&amp;&gt;</x:z>
+</root>`,
+			out: `{"root":{"@id":"1","@x:y":"2","@xmlns:x":"urn:x","item":["1",{"@n":"2","#text":"two"}],"#comment":" comment ","x:z":"<&>"}}`,
+		},
+		{
+			input: `<!-- header --><root>mixed <b>content</b>!</root>`,
+			out:   `{"#comment":" header ","root":{"#text":"mixed !","b":"content"}}`,
+		},
+		{
+			input:      xmlpi + `<root><list><item>1</item></list><list/></root>`,
+			forceArray: []string{"//item", "/root/none"},
+			out:        `{"root":{"list":[{"item":["1"]},""]}}`,
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.ToJSON(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, nil, d.forceArray, "")
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out+"\n", gotwant.Desc(seq))
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	data := []struct {
+		input string
+		err   string
+		out   string
+	}{
+		{
+			input: `{"root":{"a":"text","b":""}}`,
+			out:   `<?xml version="1.0"?><root><a>text</a><b/></root>`,
+		},
+		{
+			input: `{"#comment":" header ","root":{"@id":1,"@x:y":true,"@xmlns:x":"urn:x","item":["1",{"@n":"2","#text":"two"},null],"#comment":[" a "," b "],"x:z":"<&>"}}`,
+			out:   `<?xml version="1.0"?><!-- header --><root id="1" x:y="true" xmlns:x="urn:x"><item>1</item><item n="2">two</item><item/><!-- a --><!-- b --><x:z>&lt;&amp;&gt;</x:z></root>`,
+		},
+		{
+			input: `{"a":"1","b":"2"}`,
+			err:   `json: a single root element is required`,
+		},
+		{
+			input: `{"root":[1,2]}`,
+			err:   `json: the root element "root" must not be an array`,
+		},
+		{
+			input: `{"root":{"a b":"1"}}`,
+			err:   `json: "a b" is not a valid element name`,
+		},
+		{
+			input: `{"root":{"@a":{}}}`,
+			err:   `json: "@a" must be a string, a number, a boolean or null`,
+		},
+		{
+			input: `{"root":{"a":[[1]]}}`,
+			err:   `json: nested array in "a"`,
+		},
+		{
+			input: `["root"]`,
+			err:   `json: the top level must be an object`,
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.FromJSON(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, main.OutputConfig{EmptyElement: true})
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		if d.err != "" {
+			gotwant.Test(t, err.Error(), d.err, gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	src := `<?xml version="1.0"?><config v="1"><!-- servers --><server name="a"><port>80</port></server><server name="b"/><debug/></config>`
+
+	j := &bytes.Buffer{}
+	err := main.ToJSON(main.NewFakeCloseReader(bytes.NewBufferString(src)), j, nil, nil, "  ")
+	gotwant.TestError(t, err, nil)
+
+	out := &bytes.Buffer{}
+	err = main.FromJSON(main.NewFakeCloseReader(j), out, main.OutputConfig{EmptyElement: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), src)
+}
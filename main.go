@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"slices"
 	"strings"
 
 	"github.com/andrew-d/go-termutil"
 	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
 	"github.com/shu-go/gli/v2"
 
 	"github.com/shu-go/ennet"
@@ -21,136 +23,432 @@ type globalCmd struct {
 	Replace replaceCmd
 	Delete  deleteCmd
 	Add     addCmd
-
-	Get getCmd
+	Move    moveCmd
+	Copy    copyCmd
+	Rename  renameCmd
+	Wrap    wrapCmd
+	Unwrap  unwrapCmd
+	Sort    sortCmd
+	Patch   patchCmd
+	Merge   mergeCmd
+
+	Get      getCmd
+	Diff     diffCmd
+	Validate validateCmd
+	Fmt      fmtCmd
+
+	ToJSON   toJSONCmd   `cli:"to-json"`
+	FromJSON fromJSONCmd `cli:"from-json"`
+	ToYAML   toYAMLCmd   `cli:"to-yaml"`
+	FromYAML fromYAMLCmd `cli:"from-yaml"`
+
+	Run runCmd
 }
 
 type common struct {
 	Indent       int  `cli:"indent=NUMBER" default:"4"`
 	EmptyElement bool `cli:"empty" default:"true"`
+
+	Namespaces []string `cli:"ns=BINDING" type:"List" help:"bind a namespace prefix=uri for XPath (repeatable)"`
+
+	Preserve bool `cli:"preserve" help:"keep the original formatting of untouched nodes (same as --indent=-1)"`
+
+	InPlace bool   `cli:"in-place" help:"overwrite the input file (--in-place=SUFFIX keeps the original as FILE+SUFFIX)"`
+	Backup  string `cli:"backup=SUFFIX" help:"with --in-place, keep the original as FILE+SUFFIX"`
+
+	Validate string `cli:"validate=SCHEMA" help:"write nothing if the result is not valid against the schema (XSD, or DTD if *.dtd)"`
+
+	DTDDefaults bool `cli:"dtd-defaults" help:"add default attribute values declared in DOCTYPE"`
+
+	C14N string `cli:"c14n=MODE" type:"C14N" help:"write canonical XML (inclusive or exclusive, optionally with ,with-comments)"`
+
+	OutputEncoding string `cli:"output-encoding=ENCODING" type:"Encoding" help:"write in ENCODING (UTF-8, Shift_JIS, EUC-JP, UTF-16, ...) updating the XML declaration (default: the encoding of the input)"`
+	BOM            bool   `cli:"bom" help:"write a BOM in UTF-8 (a BOM of the input is kept without it)"`
+	EOL            string `cli:"eol" type:"Choice" choices:"lf,crlf,preserve" default:"preserve" help:"line endings, lf, crlf or preserve (as the first line of the input)"`
+
+	Output string `cli:"output,o=FILE" help:"write to FILE instead of stdout"`
+
+	Escape string `cli:"escape" type:"Choice" choices:"minimal,ascii,preserve" default:"minimal" help:"escaping of texts and attribute values, minimal, ascii (non-ASCII characters as references) or preserve (as in the input)"`
+	Quote  string `cli:"quote" type:"Choice" choices:"double,single" default:"double" help:"quote of attribute values"`
+
+	PreserveSpace []string `cli:"preserve-space=XPATH" type:"List" help:"write the content of matched elements as it is, like xml:space=preserve (repeatable)"`
+}
+
+// outputConfig returns the config for the input file filename (empty for stdin).
+func (c common) outputConfig(filename string) OutputConfig {
+	var config OutputConfig
+	if c.Preserve || c.Indent < 0 {
+		config = OutputConfig{EmptyElement: c.EmptyElement, Preserve: true, DTDDefaults: c.DTDDefaults}
+	} else {
+		config = OutputConfig{Indent: strings.Repeat(" ", c.Indent), EmptyElement: c.EmptyElement, DTDDefaults: c.DTDDefaults}
+	}
+	config.C14N, config.C14NComments, _ = ParseC14N(c.C14N)
+	config.Encoding = c.OutputEncoding
+	config.BOM = c.BOM
+	config.EOL = c.EOL
+	config.Escape = c.Escape
+	config.Quote = c.Quote
+	config.PreserveSpace = c.PreserveSpace
+	config.Namespaces, _ = ParseNamespaces(c.Namespaces)
+	if filename != "" {
+		config.InputDir = filepath.Dir(filename)
+	}
+	return config
+}
+
+// checkStream returns an error for options that need the whole document, which --stream does not hold,
+// or that change the formatting of the input copied as it is.
+func (c common) checkStream() error {
+	if c.Validate != "" {
+		return errors.New("--validate is not available with --stream")
+	}
+	if c.DTDDefaults {
+		return errors.New("--dtd-defaults is not available with --stream")
+	}
+	if c.OutputEncoding != "" || c.BOM || c.EOL != "" && c.EOL != EOLPreserve {
+		return errors.New("--output-encoding, --bom and --eol are not available with --stream")
+	}
+	// the input is copied as it is, and edited elements are written compact
+	if c.Indent != 4 || !c.EmptyElement || c.Preserve || c.C14N != "" || len(c.PreserveSpace) > 0 ||
+		c.Escape != "" && c.Escape != EscapeMinimal || c.Quote != "" && c.Quote != QuoteDouble {
+		return errors.New("--indent, --empty, --preserve, --c14n, --escape, --quote and --preserve-space are not available with --stream")
+	}
+	return nil
+}
+
+// checkConverted returns an error for options about the source of an XML input,
+// which a document converted from JSON or YAML does not have.
+func (c common) checkConverted() error {
+	if c.Preserve || c.DTDDefaults || c.Escape == EscapePreserve {
+		return errors.New("--preserve, --dtd-defaults and --escape preserve are not available for a document converted from JSON or YAML")
+	}
+	return nil
+}
+
+// write calls fn with stdout, the file of --output, or the input file if --in-place is given.
+// With --validate, the result is written only if it is valid. Violations are written to stderr.
+func (c common) write(filename string, fn func(io.Writer) error) error {
+	if c.Backup != "" && !c.InPlace {
+		return errors.New("--backup requires --in-place")
+	}
+
+	for _, expr := range c.PreserveSpace {
+		if _, err := xpath.Compile(expr); err != nil {
+			return fmt.Errorf("preserve-space: %v", err)
+		}
+	}
+
+	if c.Validate != "" {
+		schema, err := LoadValidator(c.Validate)
+		if err != nil {
+			return err
+		}
+
+		unvalidated := fn
+		fn = func(w io.Writer) error {
+			var b bytes.Buffer
+			if err := unvalidated(&b); err != nil {
+				return err
+			}
+			if err := Validate(NewFakeCloseReader(bytes.NewReader(b.Bytes())), os.Stderr, schema); err != nil {
+				return err
+			}
+			_, err := w.Write(b.Bytes())
+			return err
+		}
+	}
+
+	if c.Output != "" {
+		if c.InPlace {
+			return errors.New("--output and --in-place are exclusive")
+		}
+		return WriteFile(c.Output, fn)
+	}
+
+	if !c.InPlace {
+		return fn(os.Stdout)
+	}
+
+	if filename == "" {
+		return errors.New("--in-place requires an input file, not stdin")
+	}
+
+	return WriteInPlace(filename, c.Backup, fn)
+}
+
+// openInput opens the file args[0], or stdin if no file is given and stdin is not a terminal.
+// filename is empty for stdin.
+func openInput(args []string) (input io.ReadCloser, filename string, err error) {
+	if len(args) > 0 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, "", err
+		}
+		return f, args[0], nil
+	}
+
+	if termutil.Isatty(os.Stdin.Fd()) {
+		return nil, "", errors.New("input required")
+	}
+
+	return NewFakeCloseReader(os.Stdin), "", nil
+}
+
+// parseInput parses and closes input.
+// A nil input results in an empty document.
+// If config.Preserve, the source text is kept in config.
+func parseInput(input io.ReadCloser, config *OutputConfig) (*xmlquery.Node, error) {
+	if reflect.ValueOf(input).IsNil() {
+		return &xmlquery.Node{}, nil
+	}
+
+	r, te, err := decodeInput(input)
+	if err != nil {
+		input.Close()
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	input.Close()
+	config.encoding = te
+	config.eol = detectEOL(src)
+
+	// general entities in the internal subset
+	// (a broken DOCTYPE is reported by validation, not here)
+	var entities map[string]string
+	doctype, hasDoctype := rawDoctype(src)
+	if hasDoctype {
+		if d, err := parseDoctype(doctype); err == nil {
+			entities = d.entities
+		}
+	}
+
+	var doc *xmlquery.Node
+	if config.Preserve || config.Escape == EscapePreserve {
+		var sm *sourceMap
+		doc, sm, err = parseSource(src, entities)
+		if err != nil {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+		config.source = sm
+	} else {
+		doc, err = parseXML(src, entities)
+		if err != nil {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+	}
+	if dt := findDoctype(doc); dt != nil && hasDoctype && !config.Preserve {
+		// with comments dropped by encoding/xml
+		dt.Data = doctype
+	}
+
+	for _, expr := range config.PreserveSpace {
+		if _, err := compileXPath(doc, expr, config.Namespaces); err != nil {
+			return nil, fmt.Errorf("preserve-space: %v", err)
+		}
+	}
+
+	if config.DTDDefaults {
+		d, err := documentDTD(doc, config.InputDir)
+		if err != nil && !errors.Is(err, errExternalDTD) {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+		if d != nil {
+			d.applyDefaults(doc)
+		}
+	}
+
+	return doc, nil
+}
+
+// parseXML parses src converted into UTF-8 by decodeInput.
+func parseXML(src []byte, entities map[string]string) (*xmlquery.Node, error) {
+	return xmlquery.ParseWithOptions(bytes.NewReader(src), xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{
+			Strict:        true,
+			Entity:        entities,
+			CharsetReader: decodedCharsetReader,
+		},
+	})
 }
 
 type replaceCmd struct {
 	_ struct{} `help:"eksemel replace --xpath //* --value newvalue hoge.xml"`
 
-	XPath string `cli:"xpath" required:"true"`
-	Value string `cli:"value"`
-	Ennet string `cli:"ennet"`
+	XPath  string `cli:"xpath" required:"true"`
+	Value  string `cli:"value"`
+	Ennet  string `cli:"ennet"`
+	Target string `cli:"target" type:"Choice" choices:"text,name,outer,inner" help:"what to replace (default: the name of an element, or the value of the others; outer with --ennet)"`
+
+	Stream bool `cli:"stream" help:"read and write element by element, for a large input and a streamable XPath (ignored in run scripts)"`
 
 	common
 }
 
+// Targets of replace
+const (
+	TargetText  = "text"  // the text of an element, or the value of the others
+	TargetName  = "name"  // the name of an element or an attribute
+	TargetOuter = "outer" // the element itself, with markup
+	TargetInner = "inner" // the children of an element, with markup
+)
+
 func (c replaceCmd) Before() error {
-	if c.Value == "" && c.Ennet == "" {
+	if c.Ennet != "" && (c.Target == TargetText || c.Target == TargetName) {
+		return errors.New("--ennet is for --target outer or inner")
+	}
+	if c.Value == "" && c.Ennet == "" && c.Target != TargetText && c.Target != TargetInner {
 		return errors.New("either --value or --ennet is required")
 	}
+	if c.Stream {
+		return c.common.checkStream()
+	}
 
 	return nil
 }
 
-func Replace(input io.ReadCloser, output, errOutput io.Writer, xpath, value, abbrev string, config OutputConfig) error {
-	var doc *xmlquery.Node
-	var err error
-	if reflect.ValueOf(input).IsNil() {
-		doc = &xmlquery.Node{}
-	} else {
-		doc, err = xmlquery.Parse(input)
-		if err != nil {
-			return fmt.Errorf("input: %w", err)
-		}
-		input.Close()
+func Replace(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, value, abbrev, target string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
 	}
 
-	passthrough := false
-	nodes, err := xmlquery.QueryAll(doc, xpath)
+	replaceNodes(doc, errOutput, xpath, ns, value, abbrev, target)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func replaceNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string, value, abbrev, target string) {
+	nodes, err := queryAll(doc, xpath, ns)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "xpath: %v\n", err)
-		passthrough = true
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
+		return
 	}
 
+	var fragment []*xmlquery.Node
 	if abbrev != "" {
 		s, err := ennet.Expand(abbrev)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ennet: %v\n", err)
-			passthrough = true
+			fmt.Fprintf(errOutput, "ennet: %v\n", err)
+			return
+		}
+		fragment, err = parseFragment(s)
+		if err != nil {
+			fmt.Fprintf(errOutput, "ennet: %v\n", err)
+			return
+		}
+		if target == "" {
+			target = TargetOuter
+		}
+	} else if target == TargetOuter || target == TargetInner {
+		fragment, err = parseFragment(value)
+		if err != nil {
+			fmt.Fprintf(errOutput, "value: %v\n", err)
+			return
 		}
+	}
 
-		for _, n := range nodes {
-			if passthrough {
-				break
-			}
+	for _, n := range nodes {
+		switch target {
+		case TargetText:
+			setText(n, value)
 
-			if n.Type != xmlquery.ElementNode {
-				fmt.Fprintf(os.Stderr, "xpath: %v is not an element node\n", n.Data)
-				break
+		case TargetName:
+			if err := renameNode(n, value, ns); err != nil {
+				fmt.Fprintf(errOutput, "value: %v\n", err)
 			}
 
-			b := bytes.NewBufferString(s)
-			ewdoc, err := xmlquery.Parse(b)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "ennet: %v\n", err)
-				break
+		case TargetOuter, TargetInner:
+			if n.Type != xmlquery.ElementNode {
+				fmt.Fprintf(errOutput, "xpath: %v is not an element node\n", n.Data)
+				continue
 			}
 
-			parent := n.Parent
-			nn := ewdoc.FirstChild.NextSibling
-
-			if parent.FirstChild == n {
-				parent.FirstChild = nn
-				nn.NextSibling = n.NextSibling
-				if n.NextSibling != nil {
-					n.NextSibling.PrevSibling = nn
-				} else {
-					// nn is the last
-					parent.LastChild = nn
+			if target == TargetInner {
+				for c := n.FirstChild; c != nil; c = n.FirstChild {
+					xmlquery.RemoveFromTree(c)
 				}
-			} else if parent.LastChild == n {
-				parent.LastChild = nn
-				nn.PrevSibling = n.PrevSibling
-				if n.PrevSibling != nil {
-					n.PrevSibling.NextSibling = nn
+				for _, f := range fragment {
+					InsertNode(n, CloneNode(f), PositionLastChild)
 				}
 			} else {
-				nn.NextSibling = n.NextSibling
-				nn.PrevSibling = n.PrevSibling
-				n.PrevSibling.NextSibling = nn
-				n.NextSibling.PrevSibling = nn
+				for _, f := range fragment {
+					InsertNode(n, CloneNode(f), PositionBefore)
+				}
+				xmlquery.RemoveFromTree(n)
 			}
-		}
 
-	} else {
-		for _, n := range nodes {
+		default:
 			if n.Type == xmlquery.AttributeNode {
-				n.Parent.SetAttr(n.Data, value)
+				setText(n, value)
 			} else {
 				n.Data = value
 			}
 		}
 	}
+}
 
-	OutputXML(output, doc, config)
+// setText sets the text of an element, or the value of the other node n.
+func setText(n *xmlquery.Node, value string) {
+	switch n.Type {
+	case xmlquery.ElementNode:
+		for c := n.FirstChild; c != nil; c = n.FirstChild {
+			xmlquery.RemoveFromTree(c)
+		}
+		if value != "" {
+			xmlquery.AddChild(n, &xmlquery.Node{
+				Type: xmlquery.TextNode,
+				Data: value,
+			})
+		}
+
+	case xmlquery.AttributeNode:
+		if i := findAttr(n.Parent, n.Prefix, n.Data); i != -1 {
+			n.Parent.Attr[i].Value = value
+		}
+
+	case xmlquery.CharDataNode:
+		n.Data = value
+		if n.FirstChild != nil {
+			n.FirstChild.Data = value
+		}
+
+	default:
+		n.Data = value
+	}
+}
+
+func (c replaceCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
 
+	replaceNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.Value, c.Ennet, c.Target)
 	return nil
 }
 
 func (c replaceCmd) Run(args []string) error {
-	var input io.ReadCloser
-
-	if !termutil.Isatty(os.Stdin.Fd()) {
-		input = NewFakeCloseReader(os.Stdin)
-	} else {
-		if len(args) == 0 {
-			return errors.New("input required")
-		}
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
 
-		f, err := os.Open(args[0])
-		if err != nil {
-			return err
-		}
-		input = f
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
 	}
 
-	return Replace(input, os.Stdout, os.Stderr, c.XPath, c.Value, c.Ennet, OutputConfig{Indent: strings.Repeat(" ", c.Indent), EmptyElement: c.EmptyElement})
+	return c.write(filename, func(w io.Writer) error {
+		if c.Stream {
+			return StreamReplace(input, w, os.Stderr, c.XPath, ns, c.Value, c.Ennet, c.Target)
+		}
+		return Replace(input, w, os.Stderr, c.XPath, ns, c.Value, c.Ennet, c.Target, c.outputConfig(filename))
+	})
 }
 
 type deleteCmd struct {
@@ -158,58 +456,76 @@ type deleteCmd struct {
 
 	XPath string `cli:"xpath" required:"true"`
 
+	Stream bool `cli:"stream" help:"read and write element by element, for a large input and a streamable XPath (ignored in run scripts)"`
+
 	common
 }
 
-func Delete(input io.ReadCloser, output, errOutput io.Writer, xpath string, config OutputConfig) error {
-	var doc *xmlquery.Node
-	var err error
-	if reflect.ValueOf(input).IsNil() {
-		doc = &xmlquery.Node{}
-	} else {
-		doc, err = xmlquery.Parse(input)
-		if err != nil {
-			return fmt.Errorf("input: %w", err)
-		}
-		input.Close()
+func (c deleteCmd) Before() error {
+	if c.Stream {
+		return c.common.checkStream()
 	}
+	return nil
+}
 
-	nodes, err := xmlquery.QueryAll(doc, xpath)
+func Delete(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	deleteNodes(doc, errOutput, xpath, ns)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func deleteNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string) {
+	nodes, err := queryAll(doc, xpath, ns)
 	if err != nil {
 		fmt.Fprintf(errOutput, "xpath: %v\n", err)
-	} else {
-		for _, n := range nodes {
-			if n.Type == xmlquery.AttributeNode {
-				n.Parent.RemoveAttr(n.Data)
-			} else {
-				xmlquery.RemoveFromTree(n)
+		return
+	}
+
+	for _, n := range nodes {
+		if n.Type == xmlquery.AttributeNode {
+			if i := findAttr(n.Parent, n.Prefix, n.Data); i != -1 {
+				n.Parent.Attr = append(n.Parent.Attr[:i], n.Parent.Attr[i+1:]...)
 			}
+		} else {
+			xmlquery.RemoveFromTree(n)
 		}
 	}
+}
 
-	OutputXML(output, doc, config)
+func (c deleteCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
 
+	deleteNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns))
 	return nil
 }
 
 func (c deleteCmd) Run(args []string) error {
-	var input io.ReadCloser
-
-	if !termutil.Isatty(os.Stdin.Fd()) {
-		input = NewFakeCloseReader(os.Stdin)
-	} else {
-		if len(args) == 0 {
-			return errors.New("input required")
-		}
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
 
-		f, err := os.Open(args[0])
-		if err != nil {
-			return err
-		}
-		input = f
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
 	}
 
-	return Delete(input, os.Stdout, os.Stderr, c.XPath, OutputConfig{Indent: strings.Repeat(" ", c.Indent), EmptyElement: c.EmptyElement})
+	return c.write(filename, func(w io.Writer) error {
+		if c.Stream {
+			return StreamDelete(input, w, os.Stderr, c.XPath, ns)
+		}
+		return Delete(input, w, os.Stderr, c.XPath, ns, c.outputConfig(filename))
+	})
 }
 
 type addCmd struct {
@@ -234,30 +550,31 @@ func (c addCmd) Before() error {
 	return nil
 }
 
-func Add(input io.ReadCloser, output, errOutput io.Writer, xpath, name, value, abbrev string, sibling bool, config OutputConfig) error {
-	var doc *xmlquery.Node
-	var err error
-	if reflect.ValueOf(input).IsNil() {
-		doc = &xmlquery.Node{}
-	} else {
-		doc, err = xmlquery.Parse(input)
-		if err != nil {
-			return fmt.Errorf("input: %w", err)
-		}
-		input.Close()
+func Add(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, name, value, abbrev string, sibling bool, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
 	}
 
+	addNodes(doc, errOutput, xpath, ns, name, value, abbrev, sibling)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func addNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string, name, value, abbrev string, sibling bool) {
 	passthrough := false
-	nodes, err := xmlquery.QueryAll(doc, xpath)
+	nodes, err := queryAll(doc, xpath, ns)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "xpath: %v\n", err)
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
 		passthrough = true
 	}
 
 	if abbrev != "" {
 		s, err := ennet.Expand(abbrev)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ennet: %v\n", err)
+			fmt.Fprintf(errOutput, "ennet: %v\n", err)
 			passthrough = true
 		}
 
@@ -269,7 +586,7 @@ func Add(input io.ReadCloser, output, errOutput io.Writer, xpath, name, value, a
 			b := bytes.NewBufferString(s)
 			ewdoc, err := xmlquery.Parse(b)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "ennet: %v\n", err)
+				fmt.Fprintf(errOutput, "ennet: %v\n", err)
 				break
 			}
 
@@ -312,6 +629,7 @@ func Add(input io.ReadCloser, output, errOutput io.Writer, xpath, name, value, a
 
 			} else {
 				nn := &xmlquery.Node{
+					Type: xmlquery.ElementNode,
 					Data: name,
 				}
 				if value != "" {
@@ -324,97 +642,92 @@ func Add(input io.ReadCloser, output, errOutput io.Writer, xpath, name, value, a
 			}
 		}
 	}
-
-	OutputXML(output, doc, config)
-
-	return nil
 }
 
-func (c addCmd) Run(args []string) error {
-	var input io.ReadCloser
-
-	if !termutil.Isatty(os.Stdin.Fd()) {
-		input = NewFakeCloseReader(os.Stdin)
-	} else {
-		if len(args) == 0 {
-			return errors.New("input required")
-		}
-
-		f, err := os.Open(args[0])
-		if err != nil {
-			return err
-		}
-		input = f
+func (c addCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
 	}
 
-	return Add(input, os.Stdout, os.Stderr, c.XPath, c.Name, c.Value, c.Ennet, c.Sibling, OutputConfig{Indent: strings.Repeat(" ", c.Indent), EmptyElement: c.EmptyElement})
-}
-
-type getCmd struct {
-	_ struct{} `help:"eksemel get --xpath //*  hoge.xml"`
-
-	XPath string `cli:"xpath" required:"true"`
-
-	Multiple  bool
-	Separator string `cli:"separator,sep" type:"Separator" default:"\n"`
-
-	// uncommon
-	Indent       int  `cli:"indent=NUMBER" default:"0"`
-	EmptyElement bool `cli:"empty" default:"true"`
+	addNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.Name, c.Value, c.Ennet, c.Sibling)
+	return nil
 }
 
-func Get(input io.ReadCloser, output, errOutput io.Writer, xpath string, multiple bool, sep string, config OutputConfig) error {
-	doc, err := xmlquery.Parse(input)
+func (c addCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
 	if err != nil {
 		return err
 	}
-	input.Close()
 
-	nodes, err := xmlquery.QueryAll(doc, xpath)
+	input, filename, err := openInput(args)
 	if err != nil {
-		return fmt.Errorf("xpath: %v\n", err)
+		return err
 	}
 
-	data := ""
-	for _, n := range nodes {
-		if data != "" {
-			data += sep
-		}
-		data += n.Data
+	return c.write(filename, func(w io.Writer) error {
+		return Add(input, w, os.Stderr, c.XPath, ns, c.Name, c.Value, c.Ennet, c.Sibling, c.outputConfig(filename))
+	})
+}
 
-		if !multiple {
-			break
-		}
+// ExitCode is the exit code for err of a command telling its result by the exit code as diff(1):
+// 0 if err is nil, 1 if err is result (e.g. ErrFalse), and 2 on other errors.
+func ExitCode(err, result error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, result):
+		return 1
+	default:
+		return 2
 	}
+}
 
-	fmt.Println(data)
-
-	return nil
+// exitWithResult exits with ExitCode(err, result), writing err to stderr on errors.
+// It returns if err is nil.
+func exitWithResult(err, result error) {
+	switch ExitCode(err, result) {
+	case 1:
+		os.Exit(1)
+	case 2:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 }
 
-func (c getCmd) Run(args []string) error {
-	var input io.ReadCloser
+// Version is app version
+var Version string
 
-	if !termutil.Isatty(os.Stdin.Fd()) {
-		input = NewFakeCloseReader(os.Stdin)
-	} else {
-		if len(args) == 0 {
-			return errors.New("input required")
+func init() {
+	// List is for repeatable options.
+	// Unlike []string, a value is not split by commas, which are common in XPath.
+	gli.RegisterTypeDecoder("List", func(s string, v reflect.Value, tag reflect.StructTag, firstTime bool) error {
+		if firstTime {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 1))
 		}
+		v.Set(reflect.Append(v, reflect.ValueOf(s)))
+		return nil
+	})
 
-		f, err := os.Open(args[0])
-		if err != nil {
+	// C14N is a mode of --c14n.
+	gli.RegisterTypeDecoder("C14N", func(s string, v reflect.Value, tag reflect.StructTag, firstTime bool) error {
+		if _, _, err := ParseC14N(s); err != nil {
 			return err
 		}
-		input = f
-	}
+		v.SetString(s)
+		return nil
+	})
 
-	return Get(input, os.Stdout, os.Stderr, c.XPath, c.Multiple, c.Separator, OutputConfig{Indent: strings.Repeat(" ", c.Indent), EmptyElement: c.EmptyElement})
+	// Encoding is a name of a character encoding.
+	gli.RegisterTypeDecoder("Encoding", func(s string, v reflect.Value, tag reflect.StructTag, firstTime bool) error {
+		if _, err := lookupEncoding(s); err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	})
 }
 
-// Version is app version
-var Version string
-
 func main() {
 	app := gli.NewWith(&globalCmd{})
 	app.Name = "eksemel"
@@ -422,7 +735,7 @@ func main() {
 	app.Version = Version
 	app.Usage = ``
 	app.Copyright = "(C) 2024 Shuhei Kubota"
-	if err := app.Run(os.Args); err != nil {
+	if err := app.Run(ExpandInPlace(os.Args[1:])); err != nil {
 		os.Exit(1)
 	}
 }
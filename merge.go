@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+type mergeCmd struct {
+	_ struct{} `help:"eksemel merge --key option=@name base.xml overlay.xml" usage:"eksemel merge BASE OVERLAY  (BASE is read from stdin if only OVERLAY is given)"`
+
+	Keys []string `cli:"key=KEY" type:"List" help:"elem=xpath, identity of elements of the name relative to each element (repeatable)"`
+
+	Attrs string `cli:"attrs=POLICY" type:"Choice" choices:"overlay,base,error" default:"overlay" help:"on conflicting attributes, overlay wins, base wins, or error"`
+	Lists string `cli:"lists=POLICY" type:"Choice" choices:"append,replace" default:"append" help:"repeated elements without a key are appended to or replace those of base"`
+
+	DeleteMarker string `cli:"delete-marker=ATTR" default:"merge-delete" help:"an overlay element with ATTR=\"true\" deletes its counterpart"`
+
+	common
+}
+
+// MergeOptions tells how to merge an overlay.
+type MergeOptions struct {
+	// Keys maps element names to XPaths relative to the elements, which identify them.
+	Keys map[string]string
+
+	// Attrs is a policy on conflicting attributes: overlay (default), base or error.
+	Attrs string
+
+	// Lists is a policy on repeated elements without a key: append (default) or replace.
+	Lists string
+
+	// DeleteMarker is an attribute name. An overlay element with it "true" deletes its counterpart.
+	DeleteMarker string
+}
+
+// ParseMergeKeys parses keys in the form of elem=xpath.
+func ParseMergeKeys(keys []string) (map[string]string, error) {
+	m := make(map[string]string, len(keys))
+	for _, k := range keys {
+		name, expr, found := strings.Cut(k, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || expr == "" {
+			return nil, fmt.Errorf("key: %q is not in the form of elem=xpath", k)
+		}
+		m[name] = expr
+	}
+	return m, nil
+}
+
+// Merge merges overlay into base.
+//
+// Children of the root elements are merged recursively.
+// An overlay element is merged into the base element of the same name and key (see MergeOptions.Keys).
+// Elements without a key are merged if the name is unique among siblings in both documents.
+// Otherwise they are a list, and appended to or replace the base ones (MergeOptions.Lists).
+// Unmatched overlay elements are put after the last base element of the same name, or at the end.
+// Texts of an overlay element without child elements replace the base ones.
+func Merge(base, overlay io.ReadCloser, output io.Writer, ns map[string]string, opts MergeOptions, config OutputConfig) error {
+	doc, err := parseInput(base, &config)
+	if err != nil {
+		return err
+	}
+	odoc, err := parseInput(overlay, &OutputConfig{})
+	if err != nil {
+		return fmt.Errorf("overlay: %w", err)
+	}
+
+	m := merger{opts: opts, keys: make(map[string]*xpath.Expr)}
+	for name, expr := range opts.Keys {
+		m.keys[name], err = compileXPath(doc, expr, ns)
+		if err != nil {
+			return fmt.Errorf("key: %s: %w", name, err)
+		}
+	}
+
+	broot, oroot := rootElement(doc), rootElement(odoc)
+	switch {
+	case oroot == nil:
+		// nothing to merge
+	case broot == nil:
+		InsertNode(doc, m.clone(oroot), PositionLastChild)
+	case nodeName(broot) != nodeName(oroot):
+		return fmt.Errorf("merge: root elements differ (%s, %s)", nodeName(broot), nodeName(oroot))
+	default:
+		if err := m.merge(broot, oroot); err != nil {
+			return err
+		}
+	}
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func rootElement(doc *xmlquery.Node) *xmlquery.Node {
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+type merger struct {
+	opts MergeOptions
+	keys map[string]*xpath.Expr
+}
+
+func (m *merger) merge(b, o *xmlquery.Node) error {
+	for _, attr := range o.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == m.opts.DeleteMarker {
+			continue
+		}
+
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+
+		i := -1
+		for j, battr := range b.Attr {
+			if battr.Name == attr.Name {
+				i = j
+				break
+			}
+		}
+		switch {
+		case i == -1:
+			b.Attr = append(b.Attr, attr)
+		case b.Attr[i].Value == attr.Value || m.opts.Attrs == "base":
+			// keep
+		case m.opts.Attrs == "error":
+			return fmt.Errorf("merge: %s/@%s conflicts (%q, %q)", nodePath(b), name, b.Attr[i].Value, attr.Value)
+		default:
+			b.Attr[i].Value = attr.Value
+		}
+	}
+
+	var ochildren []*xmlquery.Node
+	hasText := false
+	for c := o.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == xmlquery.ElementNode:
+			ochildren = append(ochildren, c)
+		case (c.Type == xmlquery.TextNode || c.Type == xmlquery.CharDataNode) && !isWhitespaceText(c):
+			hasText = true
+		}
+	}
+	if len(ochildren) == 0 {
+		if hasText {
+			setText(b, o.InnerText())
+		}
+		return nil
+	}
+
+	ocount := make(map[string]int)
+	for _, oc := range ochildren {
+		ocount[nodeName(oc)]++
+	}
+
+	last := make(map[string]*xmlquery.Node) // where the next element of the name goes after
+	replaced := make(map[string]bool)
+	for _, oc := range ochildren {
+		name := nodeName(oc)
+		bchildren := childElements(b, name)
+		if last[name] == nil && len(bchildren) > 0 {
+			last[name] = bchildren[len(bchildren)-1]
+		}
+
+		var match *xmlquery.Node
+		if key, found := m.keys[name]; found {
+			okey := evaluateString(oc, key)
+			for _, bc := range bchildren {
+				if evaluateString(bc, key) == okey {
+					match = bc
+					break
+				}
+			}
+		} else if len(bchildren) > 1 || ocount[name] > 1 {
+			if m.isDeleted(oc) {
+				continue
+			}
+			if m.opts.Lists == "replace" && !replaced[name] {
+				replaced[name] = true
+				if len(bchildren) > 0 {
+					nn := m.clone(oc)
+					InsertNode(bchildren[0], nn, PositionBefore)
+					for _, bc := range bchildren {
+						xmlquery.RemoveFromTree(bc)
+					}
+					last[name] = nn
+					continue
+				}
+			}
+			last[name] = m.insert(b, last[name], oc)
+			continue
+		} else if len(bchildren) == 1 {
+			match = bchildren[0]
+		}
+
+		if m.isDeleted(oc) {
+			if match != nil {
+				xmlquery.RemoveFromTree(match)
+				if last[name] == match {
+					last[name] = nil
+				}
+			}
+			continue
+		}
+
+		if match == nil {
+			last[name] = m.insert(b, last[name], oc)
+			continue
+		}
+		if err := m.merge(match, oc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insert puts a copy of o after prev, or as the last child of parent.
+func (m *merger) insert(parent, prev, o *xmlquery.Node) *xmlquery.Node {
+	nn := m.clone(o)
+	if prev != nil && prev.Parent == parent {
+		InsertNode(prev, nn, PositionAfter)
+	} else {
+		InsertNode(parent, nn, PositionLastChild)
+	}
+	return nn
+}
+
+func (m *merger) isDeleted(o *xmlquery.Node) bool {
+	return m.opts.DeleteMarker != "" && o.SelectAttr(m.opts.DeleteMarker) == "true"
+}
+
+// clone returns a deep copy of o without delete markers.
+func (m *merger) clone(o *xmlquery.Node) *xmlquery.Node {
+	nn := CloneNode(o)
+	if m.opts.DeleteMarker == "" {
+		return nn
+	}
+
+	var strip func(n *xmlquery.Node)
+	strip = func(n *xmlquery.Node) {
+		if i := findAttr(n, "", m.opts.DeleteMarker); i != -1 {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			strip(c)
+		}
+	}
+	strip(nn)
+	return nn
+}
+
+// childElements returns child elements of n named name.
+func childElements(n *xmlquery.Node, name string) []*xmlquery.Node {
+	var elems []*xmlquery.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode && nodeName(c) == name {
+			elems = append(elems, c)
+		}
+	}
+	return elems
+}
+
+func (c mergeCmd) options() (MergeOptions, error) {
+	keys, err := ParseMergeKeys(c.Keys)
+	if err != nil {
+		return MergeOptions{}, err
+	}
+
+	return MergeOptions{
+		Keys:         keys,
+		Attrs:        c.Attrs,
+		Lists:        c.Lists,
+		DeleteMarker: c.DeleteMarker,
+	}, nil
+}
+
+func (c mergeCmd) Run(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("base and overlay files required")
+	}
+
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+	opts, err := c.options()
+	if err != nil {
+		return err
+	}
+
+	overlay, err := os.Open(args[len(args)-1])
+	if err != nil {
+		return err
+	}
+
+	base, filename, err := openInput(args[:len(args)-1])
+	if err != nil {
+		overlay.Close()
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Merge(base, overlay, w, ns, opts, c.outputConfig(filename))
+	})
+}
@@ -0,0 +1,99 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type mergetestdata struct {
+	base, overlay string
+	opts          main.MergeOptions
+
+	err string
+	out string
+}
+
+func TestMerge(t *testing.T) {
+	keys := map[string]string{"option": "@name"}
+
+	data := []mergetestdata{
+		{
+			base:    xmlpi + `<root a="1" b="2"><name>base</name><x/></root>`,
+			overlay: xmlpi + `<root b="3" c="4"><name>overlay</name><y/></root>`,
+			out:     xmlpi + `<root a="1" b="3" c="4"><name>overlay</name><x/><y/></root>`,
+		},
+		{
+			base:    xmlpi + `<root b="2"/>`,
+			overlay: xmlpi + `<root b="3"/>`,
+			opts:    main.MergeOptions{Attrs: "base"},
+			out:     xmlpi + `<root b="2"/>`,
+		},
+		{
+			base:    xmlpi + `<root><a b="2"/></root>`,
+			overlay: xmlpi + `<root><a b="3"/></root>`,
+			opts:    main.MergeOptions{Attrs: "error"},
+			err:     `merge: /root/a/@b conflicts ("2", "3")`,
+		},
+		{
+			// keyed
+			base:    xmlpi + `<options><option name="a" v="1"/><option name="b"><desc>B</desc></option><other/></options>`,
+			overlay: xmlpi + `<options><option name="b" v="2"><desc>BB</desc></option><option name="c"/></options>`,
+			opts:    main.MergeOptions{Keys: keys},
+			out:     xmlpi + `<options><option name="a" v="1"/><option name="b" v="2"><desc>BB</desc></option><option name="c"/><other/></options>`,
+		},
+		{
+			// lists
+			base:    xmlpi + `<root><i>1</i><i>2</i><z/></root>`,
+			overlay: xmlpi + `<root><i>3</i></root>`,
+			out:     xmlpi + `<root><i>1</i><i>2</i><i>3</i><z/></root>`,
+		},
+		{
+			base:    xmlpi + `<root><i>1</i><i>2</i><z/></root>`,
+			overlay: xmlpi + `<root><i>3</i><i>4</i></root>`,
+			opts:    main.MergeOptions{Lists: "replace"},
+			out:     xmlpi + `<root><i>3</i><i>4</i><z/></root>`,
+		},
+		{
+			// delete marker
+			base:    xmlpi + `<options><option name="a"/><option name="b"/><debug/></options>`,
+			overlay: xmlpi + `<options><option name="a" del="true"/><debug del="true"/><option name="c" del="false"/></options>`,
+			opts:    main.MergeOptions{Keys: keys, DeleteMarker: "del"},
+			out:     xmlpi + `<options><option name="b"/><option name="c"/></options>`,
+		},
+		{
+			base:    xmlpi + `<root/>`,
+			overlay: xmlpi + `<toor/>`,
+			err:     `merge: root elements differ (root, toor)`,
+		},
+		{
+			base:    xmlpi + "<root>\n    <a>1</a>\n</root>",
+			overlay: xmlpi + `<root><b>2</b></root>`,
+			out:     xmlpi + "<root>\n    <a>1</a>\n    <b>2</b>\n</root>",
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.Merge(
+			main.NewFakeCloseReader(bytes.NewBufferString(d.base)),
+			main.NewFakeCloseReader(bytes.NewBufferString(d.overlay)),
+			out,
+			nil,
+			d.opts,
+			main.OutputConfig{EmptyElement: true, Preserve: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		if d.err != "" {
+			gotwant.Test(t, err.Error(), d.err, gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
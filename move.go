@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type moveCmd struct {
+	_ struct{} `help:"eksemel move --xpath //src --to //dest hoge.xml"`
+
+	XPath string `cli:"xpath" help:"nodes to move" required:"true"`
+	To    string `cli:"to" help:"destination" required:"true"`
+
+	Sibling    bool `cli:"sibling" help:"as next siblings of the destination"`
+	Preceding  bool `cli:"before" help:"as previous siblings of the destination"`
+	FirstChild bool `cli:"first-child" help:"as the first children of the destination"`
+
+	common
+}
+
+type copyCmd struct {
+	_ struct{} `help:"eksemel copy --xpath //src --to //dest hoge.xml"`
+
+	XPath string `cli:"xpath" help:"nodes to copy" required:"true"`
+	To    string `cli:"to" help:"destination" required:"true"`
+
+	Sibling    bool `cli:"sibling" help:"as next siblings of the destination"`
+	Preceding  bool `cli:"before" help:"as previous siblings of the destination"`
+	FirstChild bool `cli:"first-child" help:"as the first children of the destination"`
+
+	common
+}
+
+// Positions relative to a destination
+const (
+	PositionLastChild  = "last-child"
+	PositionFirstChild = "first-child"
+	PositionBefore     = "before"
+	PositionAfter      = "after"
+)
+
+func position(sibling, before, firstChild bool) (string, error) {
+	pos := PositionLastChild
+	count := 0
+	if sibling {
+		pos = PositionAfter
+		count++
+	}
+	if before {
+		pos = PositionBefore
+		count++
+	}
+	if firstChild {
+		pos = PositionFirstChild
+		count++
+	}
+	if count > 1 {
+		return "", errors.New("--sibling, --before and --first-child are exclusive")
+	}
+
+	return pos, nil
+}
+
+func (c moveCmd) Before() error {
+	_, err := position(c.Sibling, c.Preceding, c.FirstChild)
+	return err
+}
+
+func (c copyCmd) Before() error {
+	_, err := position(c.Sibling, c.Preceding, c.FirstChild)
+	return err
+}
+
+// Move moves nodes matched by xpath to pos of each node matched by to.
+// With multiple destinations, all but the last get copies.
+// Attributes are moved to destination elements regardless of pos.
+func Move(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, to, pos string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	relocateNodes(doc, errOutput, xpath, ns, to, pos, true)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+// Copy copies nodes matched by xpath to pos of each node matched by to.
+// Attributes are copied to destination elements regardless of pos.
+func Copy(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, to, pos string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	relocateNodes(doc, errOutput, xpath, ns, to, pos, false)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func relocateNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string, to, pos string, move bool) {
+	nodes, err := queryAll(doc, xpath, ns)
+	if err != nil {
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
+		return
+	}
+	dests, err := queryAll(doc, to, ns)
+	if err != nil {
+		fmt.Fprintf(errOutput, "to: %v\n", err)
+		return
+	}
+
+	var sources []*xmlquery.Node
+	for _, n := range nodes {
+		if n.Parent == nil {
+			fmt.Fprintf(errOutput, "xpath: the document cannot be moved or copied\n")
+			continue
+		}
+		sources = append(sources, n)
+	}
+
+	var valid []*xmlquery.Node
+	for _, d := range dests {
+		if d.Type == xmlquery.AttributeNode {
+			fmt.Fprintf(errOutput, "to: %v is not an element node\n", d.Data)
+			continue
+		}
+		if (pos == PositionBefore || pos == PositionAfter) && d.Parent == nil {
+			fmt.Fprintf(errOutput, "to: the document cannot have siblings\n")
+			continue
+		}
+		if containsAny(sources, d) {
+			// a copy would go into itself, and later copies would have earlier ones
+			fmt.Fprintf(errOutput, "to: %v is in the nodes to move or copy\n", d.Data)
+			continue
+		}
+		valid = append(valid, d)
+	}
+
+	for i, d := range valid {
+		original := move && i == len(valid)-1
+
+		var prev *xmlquery.Node
+		for _, n := range sources {
+			if n.Type == xmlquery.AttributeNode {
+				relocateAttr(n, d, original, errOutput)
+				continue
+			}
+
+			nn := n
+			if original {
+				xmlquery.RemoveFromTree(n)
+			} else {
+				nn = CloneNode(n)
+			}
+
+			if prev == nil {
+				InsertNode(d, nn, pos)
+			} else if pos == PositionBefore {
+				InsertNode(d, nn, PositionBefore)
+			} else {
+				InsertNode(prev, nn, PositionAfter)
+			}
+			prev = nn
+		}
+	}
+}
+
+func relocateAttr(attr, dest *xmlquery.Node, move bool, errOutput io.Writer) {
+	if dest.Type != xmlquery.ElementNode {
+		fmt.Fprintf(errOutput, "to: attributes cannot be added to %v\n", dest.Data)
+		return
+	}
+
+	owner := attr.Parent
+	i := findAttr(owner, attr.Prefix, attr.Data)
+	if i == -1 {
+		return
+	}
+	a := owner.Attr[i]
+	if move {
+		owner.Attr = append(owner.Attr[:i], owner.Attr[i+1:]...)
+	}
+
+	for j := range dest.Attr {
+		if dest.Attr[j].Name == a.Name {
+			dest.Attr[j] = a
+			return
+		}
+	}
+	dest.Attr = append(dest.Attr, a)
+}
+
+// findAttr returns the index of the attribute of n named space:local (local if space is empty), or -1.
+func findAttr(n *xmlquery.Node, space, local string) int {
+	for i, a := range n.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsAny reports whether n is one of nodes or a descendant of them.
+func containsAny(nodes []*xmlquery.Node, n *xmlquery.Node) bool {
+	for a := n; a != nil; a = a.Parent {
+		for _, m := range nodes {
+			if a == m {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c moveCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+	pos, err := position(c.Sibling, c.Preceding, c.FirstChild)
+	if err != nil {
+		return err
+	}
+
+	relocateNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.To, pos, true)
+	return nil
+}
+
+func (c moveCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+	pos, err := position(c.Sibling, c.Preceding, c.FirstChild)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Move(input, w, os.Stderr, c.XPath, ns, c.To, pos, c.outputConfig(filename))
+	})
+}
+
+func (c copyCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+	pos, err := position(c.Sibling, c.Preceding, c.FirstChild)
+	if err != nil {
+		return err
+	}
+
+	relocateNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.To, pos, false)
+	return nil
+}
+
+func (c copyCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+	pos, err := position(c.Sibling, c.Preceding, c.FirstChild)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Copy(input, w, os.Stderr, c.XPath, ns, c.To, pos, c.outputConfig(filename))
+	})
+}
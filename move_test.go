@@ -0,0 +1,157 @@
+package main_test
+
+import (
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type movetestdata struct {
+	input string
+	xpath string
+	to    string
+	pos   string
+
+	err         error
+	out, errout string
+}
+
+func testmove(t *testing.T, copy bool, data []movetestdata) {
+	t.Helper()
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+
+		f := main.Move
+		if copy {
+			f = main.Copy
+		}
+		pos := d.pos
+		if pos == "" {
+			pos = main.PositionLastChild
+		}
+		err := f(
+			main.NewFakeCloseReader(in),
+			out,
+			errout,
+			d.xpath,
+			nil,
+			d.to,
+			pos,
+			main.OutputConfig{EmptyElement: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), d.errout, gotwant.Desc(seq))
+	}
+}
+
+func TestMove(t *testing.T) {
+	testmove(t, false, []movetestdata{
+		{
+			input: xmlpi + `<root><a><x/></a><b/></root>`,
+			xpath: `//x`,
+			to:    `//b`,
+			out:   xmlpi + `<root><a/><b><x/></b></root>`,
+		},
+		{
+			input: xmlpi + `<root><a><x/><y/></a><b><c/></b></root>`,
+			xpath: `//a/*`,
+			to:    `//b`,
+			pos:   main.PositionFirstChild,
+			out:   xmlpi + `<root><a/><b><x/><y/><c/></b></root>`,
+		},
+		{
+			input: xmlpi + `<root><a><x/><y/></a><b/><c/></root>`,
+			xpath: `//a/*`,
+			to:    `//c`,
+			pos:   main.PositionBefore,
+			out:   xmlpi + `<root><a/><b/><x/><y/><c/></root>`,
+		},
+		{
+			input: xmlpi + `<root><a><x/><y/></a><b/><c/></root>`,
+			xpath: `//a/*`,
+			to:    `//b`,
+			pos:   main.PositionAfter,
+			out:   xmlpi + `<root><a/><b/><x/><y/><c/></root>`,
+		},
+		{
+			// one source to many destinations
+			input: xmlpi + `<root><a><x id="1">text</x></a><b/><b/></root>`,
+			xpath: `//x`,
+			to:    `//b`,
+			out:   xmlpi + `<root><a/><b><x id="1">text</x></b><b><x id="1">text</x></b></root>`,
+		},
+		{
+			input: xmlpi + `<root><a id="1"/><b/></root>`,
+			xpath: `//a/@id`,
+			to:    `//b`,
+			out:   xmlpi + `<root><a/><b id="1"/></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a><b/></a></root>`,
+			xpath:  `//a`,
+			to:     `//b`,
+			out:    xmlpi + `<root><a><b/></a></root>`,
+			errout: "to: b is in the nodes to move or copy\n",
+		},
+		{
+			input: xmlpi + `<root><a/></root>`,
+			xpath: `//x`,
+			to:    `//a`,
+			out:   xmlpi + `<root><a/></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a/></root>`,
+			xpath:  `//a`,
+			to:     `//[`,
+			out:    xmlpi + `<root><a/></root>`,
+			errout: "to: expression must evaluate to a node-set\n",
+		},
+	})
+}
+
+func TestCopy(t *testing.T) {
+	testmove(t, true, []movetestdata{
+		{
+			input: xmlpi + `<root><a><x/></a><b/></root>`,
+			xpath: `//x`,
+			to:    `//b`,
+			out:   xmlpi + `<root><a><x/></a><b><x/></b></root>`,
+		},
+		{
+			input: xmlpi + `<root><a><x id="1"><y>text</y><!--c--></x></a><b/><b/></root>`,
+			xpath: `//x`,
+			to:    `//b`,
+			pos:   main.PositionAfter,
+			out:   xmlpi + `<root><a><x id="1"><y>text</y><!--c--></x></a><b/><x id="1"><y>text</y><!--c--></x><b/><x id="1"><y>text</y><!--c--></x></root>`,
+		},
+		{
+			// copy into itself
+			input:  xmlpi + `<root><a><b/></a></root>`,
+			xpath:  `//a`,
+			to:     `//b`,
+			out:    xmlpi + `<root><a><b/></a></root>`,
+			errout: "to: b is in the nodes to move or copy\n",
+		},
+		{
+			// the destination in the source is skipped, and the others get copies without nesting
+			input:  xmlpi + `<root><a><b/></a><b/></root>`,
+			xpath:  `//a`,
+			to:     `//b | //a`,
+			out:    xmlpi + `<root><a><b/></a><b><a><b/></a></b></root>`,
+			errout: "to: b is in the nodes to move or copy\nto: a is in the nodes to move or copy\n",
+		},
+		{
+			input: xmlpi + `<root><a id="1"/><b id="2"/></root>`,
+			xpath: `//a/@id`,
+			to:    `//b`,
+			out:   xmlpi + `<root><a id="1"/><b id="1"/></root>`,
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// ParseNamespaces parses bindings in the form of prefix=uri.
+func ParseNamespaces(bindings []string) (map[string]string, error) {
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+
+	ns := make(map[string]string, len(bindings))
+	for _, b := range bindings {
+		prefix, uri, found := strings.Cut(b, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !found || prefix == "" {
+			return nil, fmt.Errorf("ns: %q is not in the form of prefix=uri", b)
+		}
+		ns[prefix] = strings.TrimSpace(uri)
+	}
+
+	return ns, nil
+}
+
+// mergeNamespaces returns bindings of base overridden by ns.
+func mergeNamespaces(base, ns map[string]string) map[string]string {
+	if len(ns) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return ns
+	}
+
+	merged := maps.Clone(base)
+	maps.Copy(merged, ns)
+	return merged
+}
+
+// compileXPath compiles expr with namespace bindings.
+//
+// Prefixes declared in doc are bound automatically. Declarations on the root element take precedence
+// over deeper ones, and ns takes precedence over all of them.
+func compileXPath(doc *xmlquery.Node, expr string, ns map[string]string) (*xpath.Expr, error) {
+	bindings := declaredNamespaces(doc)
+	maps.Copy(bindings, ns)
+
+	if len(bindings) == 0 {
+		return xpath.Compile(expr)
+	}
+	return xpath.CompileWithNS(expr, bindings)
+}
+
+// queryAll is xmlquery.QueryAll with namespace bindings. See compileXPath.
+// Attribute nodes have their prefixes, so that @x:a and @a are told apart.
+func queryAll(doc *xmlquery.Node, expr string, ns map[string]string) ([]*xmlquery.Node, error) {
+	exp, err := compileXPath(doc, expr, ns)
+	if err != nil {
+		return nil, err
+	}
+	return selectNodes(doc, exp), nil
+}
+
+// selectNodes is xmlquery.QuerySelectorAll, with the prefixes of attribute nodes.
+func selectNodes(doc *xmlquery.Node, exp *xpath.Expr) []*xmlquery.Node {
+	var nodes []*xmlquery.Node
+	t := exp.Select(xmlquery.CreateXPathNavigator(doc))
+	for t.MoveNext() {
+		nav := t.Current().(*xmlquery.NodeNavigator)
+		n := nav.Current()
+		if nav.NodeType() == xpath.AttributeNode {
+			// as xmlquery.QuerySelectorAll, with the prefix
+			value := &xmlquery.Node{Type: xmlquery.TextNode, Data: nav.Value()}
+			n = &xmlquery.Node{
+				Parent:       n,
+				Type:         xmlquery.AttributeNode,
+				Data:         nav.LocalName(),
+				Prefix:       nav.Prefix(),
+				NamespaceURI: nav.NamespaceURL(),
+				FirstChild:   value,
+				LastChild:    value,
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// declaredNamespaces collects xmlns:prefix declarations in document order.
+func declaredNamespaces(n *xmlquery.Node) map[string]string {
+	ns := make(map[string]string)
+
+	var walk func(n *xmlquery.Node)
+	walk = func(n *xmlquery.Node) {
+		if n.Type == xmlquery.ElementNode {
+			for _, attr := range n.Attr {
+				if attr.Name.Space != "xmlns" {
+					continue
+				}
+				if _, found := ns[attr.Name.Local]; !found {
+					ns[attr.Name.Local] = attr.Value
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	return ns
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type patchCmd struct {
+	_ struct{} `help:"eksemel patch --patch changes.xml hoge.xml" usage:"eksemel patch --generate old.xml new.xml  (writes a patch from old.xml to new.xml)"`
+
+	Patch    string `cli:"patch=FILE" help:"an XML patch document (RFC 5261) to apply"`
+	Generate bool   `cli:"generate" help:"write a patch from the first file to the second"`
+
+	common
+}
+
+func (c patchCmd) Before() error {
+	if (c.Patch == "") == !c.Generate {
+		return errors.New("either --patch or --generate is required")
+	}
+
+	return nil
+}
+
+// Patch applies an XML patch document (RFC 5261) to input.
+//
+// Operations are <add sel pos type>, <replace sel> and <remove sel ws> in a root element (<diff>).
+// sel must match exactly one node. Prefixes declared in the patch document are available in sel.
+// Leading and trailing whitespace-only texts of the content are ignored if the content has other nodes.
+func Patch(input io.ReadCloser, output io.Writer, patch io.ReadCloser, ns map[string]string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	pdoc, err := parseInput(patch, &OutputConfig{})
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+
+	if err := applyPatch(doc, pdoc, ns); err != nil {
+		return err
+	}
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func applyPatch(doc, pdoc *xmlquery.Node, ns map[string]string) error {
+	root := pdoc.FirstChild
+	for root != nil && root.Type != xmlquery.ElementNode {
+		root = root.NextSibling
+	}
+	if root == nil {
+		return errors.New("patch: no operations")
+	}
+
+	ns = mergeNamespaces(declaredNamespaces(pdoc), ns)
+
+	for op := root.FirstChild; op != nil; op = op.NextSibling {
+		if op.Type != xmlquery.ElementNode {
+			continue
+		}
+
+		sel := op.SelectAttr("sel")
+		nodes, err := queryAll(doc, sel, ns)
+		if err != nil {
+			return fmt.Errorf("patch: %s sel=%q: %w", op.Data, sel, err)
+		}
+		if len(nodes) != 1 {
+			return fmt.Errorf("patch: %s sel=%q matched %d nodes", op.Data, sel, len(nodes))
+		}
+		target := nodes[0]
+
+		switch op.Data {
+		case "add":
+			err = patchAdd(target, op)
+		case "replace":
+			err = patchReplace(target, op)
+		case "remove":
+			err = patchRemove(target, op)
+		default:
+			err = errors.New("unknown operation")
+		}
+		if err != nil {
+			return fmt.Errorf("patch: %s sel=%q: %w", op.Data, sel, err)
+		}
+	}
+
+	return nil
+}
+
+func patchAdd(target, op *xmlquery.Node) error {
+	if typ := op.SelectAttr("type"); typ != "" {
+		if target.Type != xmlquery.ElementNode {
+			return errors.New("type requires an element")
+		}
+
+		if prefix, found := strings.CutPrefix(typ, "namespace::"); found {
+			setAttr(target, "xmlns:"+prefix, op.InnerText())
+		} else if name, found := strings.CutPrefix(typ, "@"); found {
+			setAttr(target, name, op.InnerText())
+		} else {
+			return fmt.Errorf("unknown type %q", typ)
+		}
+		return nil
+	}
+
+	pos := op.SelectAttr("pos")
+	if (pos == "before" || pos == "after") && (target.Parent == nil || target.Type == xmlquery.AttributeNode) {
+		return fmt.Errorf("%v cannot have siblings", target.Data)
+	}
+	if (pos == "" || pos == "prepend") && target.Type != xmlquery.ElementNode && target.Type != xmlquery.DocumentNode {
+		return fmt.Errorf("%v cannot have children", target.Data)
+	}
+
+	var prev *xmlquery.Node
+	for _, c := range patchContent(op) {
+		nn := CloneNode(c)
+		switch {
+		case pos == "":
+			InsertNode(target, nn, PositionLastChild)
+		case pos == "before":
+			InsertNode(target, nn, PositionBefore)
+		case prev != nil:
+			InsertNode(prev, nn, PositionAfter)
+		case pos == "prepend":
+			InsertNode(target, nn, PositionFirstChild)
+		case pos == "after":
+			InsertNode(target, nn, PositionAfter)
+		default:
+			return fmt.Errorf("unknown pos %q", pos)
+		}
+		prev = nn
+	}
+
+	return nil
+}
+
+func patchReplace(target, op *xmlquery.Node) error {
+	if target.Type == xmlquery.AttributeNode {
+		setText(target, op.InnerText())
+		return nil
+	}
+	if target.Parent == nil {
+		return errors.New("the document cannot be replaced")
+	}
+
+	for _, c := range patchContent(op) {
+		InsertNode(target, CloneNode(c), PositionBefore)
+	}
+	xmlquery.RemoveFromTree(target)
+
+	return nil
+}
+
+func patchRemove(target, op *xmlquery.Node) error {
+	if target.Type == xmlquery.AttributeNode {
+		owner := target.Parent
+		if i := findAttr(owner, target.Prefix, target.Data); i != -1 {
+			owner.Attr = append(owner.Attr[:i], owner.Attr[i+1:]...)
+		}
+		return nil
+	}
+	if target.Parent == nil {
+		return errors.New("the document cannot be removed")
+	}
+
+	ws := op.SelectAttr("ws")
+	if ws == "before" || ws == "both" {
+		if isWhitespaceText(target.PrevSibling) {
+			xmlquery.RemoveFromTree(target.PrevSibling)
+		}
+	}
+	if ws == "after" || ws == "both" {
+		if isWhitespaceText(target.NextSibling) {
+			xmlquery.RemoveFromTree(target.NextSibling)
+		}
+	}
+	xmlquery.RemoveFromTree(target)
+
+	return nil
+}
+
+// patchContent returns the children of op.
+// Leading and trailing whitespace-only texts are dropped if there are other nodes.
+func patchContent(op *xmlquery.Node) []*xmlquery.Node {
+	var content []*xmlquery.Node
+	for c := op.FirstChild; c != nil; c = c.NextSibling {
+		content = append(content, c)
+	}
+
+	for len(content) > 1 && isWhitespaceText(content[0]) {
+		content = content[1:]
+	}
+	for len(content) > 1 && isWhitespaceText(content[len(content)-1]) {
+		content = content[:len(content)-1]
+	}
+	return content
+}
+
+// setAttr sets an attribute name (may have a prefix) of n.
+func setAttr(n *xmlquery.Node, name, value string) {
+	prefix, local, found := strings.Cut(name, ":")
+	if !found {
+		prefix, local = "", name
+	}
+
+	for i, attr := range n.Attr {
+		if attr.Name.Space == prefix && attr.Name.Local == local {
+			n.Attr[i].Value = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, xmlquery.Attr{
+		Name:  xml.Name{Space: prefix, Local: local},
+		Value: value,
+	})
+}
+
+// GeneratePatch writes an XML patch document (RFC 5261) that changes old into new.
+//
+// Like Diff, whitespace-only texts and the order of attributes are not taken into account.
+func GeneratePatch(old, new io.ReadCloser, output io.Writer, config OutputConfig) error {
+	odoc, err := parseInput(old, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+	ndoc, err := parseInput(new, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+
+	pdoc, err := xmlquery.Parse(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?><diff/>`))
+	if err != nil {
+		return err
+	}
+	g := patchGenerator{
+		diff: pdoc.LastChild,
+	}
+
+	ns := declaredNamespaces(odoc)
+	for prefix, uri := range declaredNamespaces(ndoc) {
+		if _, found := ns[prefix]; !found {
+			ns[prefix] = uri
+		}
+	}
+	for _, prefix := range sortedKeys(ns) {
+		setAttr(g.diff, "xmlns:"+prefix, ns[prefix])
+	}
+
+	// old is modified along with the operations, so that each sel is valid at the time.
+	g.children(odoc, ndoc)
+
+	OutputXML(output, pdoc, config)
+
+	return nil
+}
+
+type patchGenerator struct {
+	diff *xmlquery.Node
+}
+
+func (g *patchGenerator) op(name, sel string, attrs ...string) *xmlquery.Node {
+	op := &xmlquery.Node{
+		Type: xmlquery.ElementNode,
+		Data: name,
+	}
+	setAttr(op, "sel", sel)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		setAttr(op, attrs[i], attrs[i+1])
+	}
+	InsertNode(g.diff, op, PositionLastChild)
+	return op
+}
+
+func (g *patchGenerator) text(op *xmlquery.Node, value string) {
+	if value != "" {
+		InsertNode(op, &xmlquery.Node{Type: xmlquery.TextNode, Data: value}, PositionLastChild)
+	}
+}
+
+func (g *patchGenerator) children(o, n *xmlquery.Node) {
+	d := &differ{}
+
+	var prev *xmlquery.Node
+	for _, p := range alignNodes(d.children(o), d.children(n)) {
+		switch {
+		case p.a != nil && p.b != nil:
+			g.node(p.a, p.b)
+			prev = p.a
+
+		case p.a != nil:
+			g.op("remove", nodePath(p.a))
+			xmlquery.RemoveFromTree(p.a)
+
+		default:
+			var op *xmlquery.Node
+			nn := CloneNode(p.b)
+			if prev == nil {
+				op = g.op("add", nodePath(o), "pos", "prepend")
+				InsertNode(o, nn, PositionFirstChild)
+			} else {
+				op = g.op("add", nodePath(prev), "pos", "after")
+				InsertNode(prev, nn, PositionAfter)
+			}
+			InsertNode(op, CloneNode(p.b), PositionLastChild)
+			prev = nn
+		}
+	}
+}
+
+func (g *patchGenerator) node(o, n *xmlquery.Node) {
+	if o.Type != xmlquery.ElementNode {
+		if diffText(o) != diffText(n) {
+			op := g.op("replace", nodePath(o))
+			if o.Type == xmlquery.TextNode || o.Type == xmlquery.CharDataNode {
+				g.text(op, nodeText(n))
+			} else {
+				InsertNode(op, CloneNode(n), PositionLastChild)
+			}
+		}
+		return
+	}
+
+	oattrs, nattrs := nodeAttrs(o), nodeAttrs(n)
+	path := nodePath(o)
+	for _, name := range sortedKeys(oattrs) {
+		nv, found := nattrs[name]
+		if !found {
+			g.op("remove", path+"/@"+name)
+		} else if nv != oattrs[name] {
+			g.text(g.op("replace", path+"/@"+name), nv)
+		}
+	}
+	for _, name := range sortedKeys(nattrs) {
+		if _, found := oattrs[name]; !found {
+			g.text(g.op("add", path, "type", "@"+name), nattrs[name])
+		}
+	}
+
+	g.children(o, n)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c patchCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	if c.Generate {
+		return errors.New("--generate is not available in scripts")
+	}
+
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.Patch)
+	if err != nil {
+		return err
+	}
+	pdoc, err := parseInput(f, &OutputConfig{})
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+
+	return applyPatch(doc, pdoc, mergeNamespaces(ns, cns))
+}
+
+func (c patchCmd) Run(args []string) error {
+	if c.Generate {
+		if len(args) != 2 {
+			return errors.New("old and new files required")
+		}
+		if c.InPlace {
+			return errors.New("--in-place is not available with --generate")
+		}
+
+		old, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		new, err := os.Open(args[1])
+		if err != nil {
+			old.Close()
+			return err
+		}
+
+		return c.write("", func(w io.Writer) error {
+			return GeneratePatch(old, new, w, c.outputConfig(""))
+		})
+	}
+
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	patch, err := os.Open(c.Patch)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		patch.Close()
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Patch(input, w, patch, ns, c.outputConfig(filename))
+	})
+}
@@ -0,0 +1,140 @@
+package main_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type patchtestdata struct {
+	input string
+	patch string
+
+	err error
+	out string
+}
+
+func TestPatch(t *testing.T) {
+	data := []patchtestdata{
+		{
+			input: xmlpi + `<root><a/><b id="1"/></root>`,
+			patch: `<diff>
+  <add sel="/root"><c/></add>
+  <add sel="/root/a" pos="before"><!--first--></add>
+  <add sel="/root/a" pos="after"><x/><y/></add>
+  <add sel="/root" pos="prepend">text</add>
+  <add sel="/root/b" type="@name">B</add>
+  <replace sel="/root/b/@id">2</replace>
+</diff>`,
+			out: xmlpi + `<root>text<!--first--><a/><x/><y/><b id="2" name="B"/><c/></root>`,
+		},
+		{
+			input: xmlpi + `<root><a>old</a><b/><c/></root>`,
+			patch: `<diff><replace sel="/root/a/text()">new</replace><replace sel="/root/b"><d>D</d></replace><remove sel="/root/c"/></diff>`,
+			out:   xmlpi + `<root><a>new</a><d>D</d></root>`,
+		},
+		{
+			input: xmlpi + "<root>\n  <a/>\n  <b x=\"1\"/>\n</root>",
+			patch: `<diff><remove sel="/root/a" ws="before"/><remove sel="/root/b/@x"/></diff>`,
+			out:   xmlpi + "<root>\n  <b/>\n</root>",
+		},
+		{
+			// prefixes of the patch document
+			input: xmlpi + `<root xmlns="urn:r"><a/></root>`,
+			patch: `<diff xmlns:r="urn:r"><add sel="/r:root/r:a" type="@id">1</add></diff>`,
+			out:   xmlpi + `<root xmlns="urn:r"><a id="1"/></root>`,
+		},
+		{
+			input: xmlpi + `<root><a/><a/></root>`,
+			patch: `<diff><remove sel="/root/a"/></diff>`,
+			err:   errors.New(`patch: remove sel="/root/a" matched 2 nodes`),
+		},
+		{
+			input: xmlpi + `<root><a/></root>`,
+			patch: `<diff><move sel="/root/a"/></diff>`,
+			err:   errors.New(`patch: move sel="/root/a": unknown operation`),
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.Patch(
+			main.NewFakeCloseReader(bytes.NewBufferString(d.input)),
+			out,
+			main.NewFakeCloseReader(bytes.NewBufferString(d.patch)),
+			nil,
+			main.OutputConfig{EmptyElement: true, Preserve: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		if d.err != nil {
+			gotwant.Test(t, err.Error(), d.err.Error(), gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestGeneratePatch(t *testing.T) {
+	data := []struct {
+		old, new string
+		patch    string
+	}{
+		{
+			old:   xmlpi + `<root><a x="1" y="2">text</a><b/><c/></root>`,
+			new:   xmlpi + `<root><a x="3" z="4">new</a><n/><c/><d>d</d></root>`,
+			patch: xmlpi + `<diff><replace sel="/root/a/@x">3</replace><remove sel="/root/a/@y"/><add sel="/root/a" type="@z">4</add><replace sel="/root/a/text()">new</replace><remove sel="/root/b"/><add sel="/root/a" pos="after"><n/></add><add sel="/root/c" pos="after"><d>d</d></add></diff>`,
+		},
+		{
+			old:   xmlpi + "<root>\n  <i/>\n  <i/>\n</root>",
+			new:   xmlpi + `<root><j/><i/><i><k/></i></root>`,
+			patch: xmlpi + `<diff><add sel="/root" pos="prepend"><j/></add><add sel="/root/i[2]" pos="prepend"><k/></add></diff>`,
+		},
+		{
+			old:   xmlpi + `<root xmlns:x="urn:x"><x:a/></root>`,
+			new:   xmlpi + `<root xmlns:x="urn:x"><x:a><!--c--></x:a></root>`,
+			patch: xmlpi + `<diff xmlns:x="urn:x"><add sel="/root/x:a" pos="prepend"><!--c--></add></diff>`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		patch := &bytes.Buffer{}
+		err := main.GeneratePatch(
+			main.NewFakeCloseReader(bytes.NewBufferString(d.old)),
+			main.NewFakeCloseReader(bytes.NewBufferString(d.new)),
+			patch,
+			main.OutputConfig{EmptyElement: true},
+		)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, patch.String(), d.patch, gotwant.Desc(seq))
+
+		// old + patch = new
+		patched := &bytes.Buffer{}
+		err = main.Patch(
+			main.NewFakeCloseReader(bytes.NewBufferString(d.old)),
+			patched,
+			main.NewFakeCloseReader(bytes.NewBufferString(patch.String())),
+			nil,
+			main.OutputConfig{EmptyElement: true},
+		)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+
+		err = main.Diff(
+			main.NewFakeCloseReader(bytes.NewBufferString(patched.String())),
+			main.NewFakeCloseReader(bytes.NewBufferString(d.new)),
+			&bytes.Buffer{},
+			nil,
+			nil,
+			"text",
+		)
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+	}
+}
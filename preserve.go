@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// sourceMap keeps the source text of each node of a parsed document,
+// so that untouched nodes are written as they were.
+type sourceMap struct {
+	nodes map[*xmlquery.Node]*sourceNode
+}
+
+type sourceNode struct {
+	raw    string // the start tag of an element, or the whole node
+	endRaw string // the end tag of an element, empty if self-closing
+
+	own      string // snapshot of the name, attributes or data
+	children []*xmlquery.Node
+}
+
+// parseSource parses src and records the source text of each node.
+func parseSource(src []byte, entities map[string]string) (*xmlquery.Node, *sourceMap, error) {
+	doc, err := parseXML(src, entities)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sm := &sourceMap{nodes: make(map[*xmlquery.Node]*sourceNode)}
+
+	// xmlquery creates nodes in document order, and always appends them to the rightmost of the tree.
+	var order []*xmlquery.Node
+	for _, n := range prologNodes(doc) {
+		order = appendPreorder(order, n)
+	}
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		order = appendPreorder(order, n)
+	}
+
+	var elems []*sourceNode
+	d := xml.NewDecoder(bytes.NewReader(src))
+	d.Entity = entities
+	d.CharsetReader = decodedCharsetReader
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		raw := string(src[start:d.InputOffset()])
+
+		if _, ok := tok.(xml.EndElement); ok {
+			elems[len(elems)-1].endRaw = raw
+			elems = elems[:len(elems)-1]
+			continue
+		}
+
+		if len(order) > 0 && order[0].Type == xmlquery.DeclarationNode && order[0].Data == "xml" {
+			if _, ok := tok.(xml.ProcInst); !ok {
+				// missing XML declaration supplemented by xmlquery
+				sm.nodes[order[0]] = &sourceNode{own: ownSnapshot(order[0])}
+				order = order[1:]
+			}
+		}
+		if len(order) == 0 {
+			return nil, nil, errors.New("preserve: unexpected token")
+		}
+
+		n := order[0]
+		order = order[1:]
+
+		if !sameNodeType(n, tok) {
+			return nil, nil, fmt.Errorf("preserve: unexpected node %q", n.Data)
+		}
+
+		sn := &sourceNode{raw: raw, own: ownSnapshot(n)}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			sn.children = append(sn.children, c)
+		}
+		sm.nodes[n] = sn
+
+		if _, ok := tok.(xml.StartElement); ok {
+			elems = append(elems, sn)
+		}
+	}
+
+	return doc, sm, nil
+}
+
+func appendPreorder(list []*xmlquery.Node, n *xmlquery.Node) []*xmlquery.Node {
+	list = append(list, n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		list = appendPreorder(list, c)
+	}
+	return list
+}
+
+func sameNodeType(n *xmlquery.Node, tok xml.Token) bool {
+	switch tok.(type) {
+	case xml.StartElement:
+		return n.Type == xmlquery.ElementNode
+	case xml.CharData:
+		return n.Type == xmlquery.TextNode || n.Type == xmlquery.CharDataNode
+	case xml.Comment:
+		return n.Type == xmlquery.CommentNode
+	case xml.ProcInst:
+		return n.Type == xmlquery.DeclarationNode
+	case xml.Directive:
+		return n.Type == xmlquery.NotationNode
+	}
+	return false
+}
+
+func ownSnapshot(n *xmlquery.Node) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%d\x00%s\x00%s", n.Type, n.Prefix, n.Data)
+	for _, attr := range n.Attr {
+		fmt.Fprintf(&s, "\x00%s\x00%s\x00%s", attr.Name.Space, attr.Name.Local, attr.Value)
+	}
+	return s.String()
+}
+
+func (sm *sourceMap) write(b *bufio.Writer, n *xmlquery.Node, config OutputConfig) {
+	if n.Type == xmlquery.DocumentNode {
+		for _, p := range prologNodes(n) {
+			sm.writeNode(b, p, config)
+		}
+		sm.writeChildren(b, n, config)
+		return
+	}
+
+	sm.writeNode(b, n, config)
+}
+
+func (sm *sourceMap) writeNode(b *bufio.Writer, n *xmlquery.Node, config OutputConfig) {
+	sn := sm.nodes[n]
+
+	if n.Type != xmlquery.ElementNode {
+		if sn != nil && sn.own == ownSnapshot(n) {
+			b.WriteString(sn.raw)
+			return
+		}
+
+		switch n.Type {
+		case xmlquery.TextNode:
+			b.WriteString(config.text(n, n.Data))
+		case xmlquery.CharDataNode:
+			b.WriteString(cdata(n.Data))
+		case xmlquery.CommentNode:
+			b.WriteString("<!--" + n.Data + "-->")
+		case xmlquery.NotationNode:
+			b.WriteString("<!" + n.Data + ">")
+		case xmlquery.DeclarationNode:
+			b.WriteString("<?" + n.Data)
+			writePIAttrs(b, n.Attr)
+			b.WriteString("?>")
+		}
+		return
+	}
+
+	selfClosing := config.EmptyElement
+	if sn != nil {
+		selfClosing = sn.endRaw == ""
+	}
+
+	untouched := sn != nil && sn.own == ownSnapshot(n) && !(selfClosing && n.FirstChild != nil)
+	if untouched {
+		b.WriteString(sn.raw)
+		if selfClosing {
+			return
+		}
+	} else {
+		b.WriteByte('<')
+		writeName(b, n.Prefix, n.Data)
+		writeAttrs(b, n, config)
+		if n.FirstChild == nil && selfClosing {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteByte('>')
+	}
+
+	sm.writeChildren(b, n, config)
+
+	if untouched {
+		b.WriteString(sn.endRaw)
+	} else {
+		b.WriteString("</")
+		writeName(b, n.Prefix, n.Data)
+		b.WriteByte('>')
+	}
+}
+
+// writeChildren writes children of n.
+//
+// Added nodes are indented like the original first child, and are put before the trailing whitespace.
+// Whitespace left by removed nodes is collapsed.
+func (sm *sourceMap) writeChildren(b *bufio.Writer, n *xmlquery.Node, config OutputConfig) {
+	indent := ""
+	if sn := sm.nodes[n]; sn != nil && len(sn.children) > 0 {
+		if first := sn.children[0]; isWhitespaceText(first) && strings.Contains(first.Data, "\n") {
+			indent = sm.nodes[first].raw
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		_, original := sm.nodes[c]
+
+		if !original {
+			b.WriteString(indent)
+			sm.writeNode(b, c, config)
+			continue
+		}
+
+		if isWhitespaceText(c) {
+			if isWhitespaceText(c.NextSibling) {
+				continue
+			}
+
+			trailing := c.NextSibling != nil
+			for s := c.NextSibling; s != nil; s = s.NextSibling {
+				if _, found := sm.nodes[s]; found {
+					trailing = false
+					break
+				}
+			}
+			if trailing {
+				for s := c.NextSibling; s != nil; s = s.NextSibling {
+					b.WriteString(indent)
+					sm.writeNode(b, s, config)
+				}
+				sm.writeNode(b, c, config)
+				return
+			}
+		}
+
+		sm.writeNode(b, c, config)
+	}
+}
+
+func isWhitespaceText(n *xmlquery.Node) bool {
+	return n != nil && n.Type == xmlquery.TextNode && strings.TrimSpace(n.Data) == ""
+}
@@ -0,0 +1,120 @@
+package main_test
+
+import (
+	"io"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+const preservesrc = `<?xml version='1.0' standalone="yes"?>
+<!-- header -->
+<xml>
+    <command name='add' >
+        <options>
+            <option name="xpath">
+                <desc>An XPath &amp; &#x41;</desc>
+            </option>
+            <option name="name"></option>
+            <option name="value"/>
+        </options>
+    </command>
+</xml>
+`
+
+func TestPreserve(t *testing.T) {
+	config := main.OutputConfig{EmptyElement: true, Preserve: true}
+
+	t.Run("Untouched", func(t *testing.T) {
+		in, out, errout := prepare(preservesrc)
+		err := main.Delete(main.NewFakeCloseReader(in), out, errout, `//nothing`, nil, config)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, readAll(out), preservesrc)
+	})
+
+	t.Run("NoDeclaration", func(t *testing.T) {
+		src := "\n<!DOCTYPE xml>\n<xml a = 'b'><?pi x?><![CDATA[<c>]]></xml>\n"
+		in, out, errout := prepare(src)
+		err := main.Delete(main.NewFakeCloseReader(in), out, errout, `//nothing`, nil, config)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, readAll(out), src)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		in, out, errout := prepare(preservesrc)
+		err := main.Delete(main.NewFakeCloseReader(in), out, errout, `//option[@name!='name']`, nil, config)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, readAll(out), `<?xml version='1.0' standalone="yes"?>
+<!-- header -->
+<xml>
+    <command name='add' >
+        <options>
+            <option name="name"></option>
+        </options>
+    </command>
+</xml>
+`)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		in, out, errout := prepare(preservesrc)
+		err := main.Replace(main.NewFakeCloseReader(in), out, errout, `//option[@name='name']/@name`, nil, "NAME", "", "", config)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, readAll(out), `<?xml version='1.0' standalone="yes"?>
+<!-- header -->
+<xml>
+    <command name='add' >
+        <options>
+            <option name="xpath">
+                <desc>An XPath &amp; &#x41;</desc>
+            </option>
+            <option name="NAME"></option>
+            <option name="value"/>
+        </options>
+    </command>
+</xml>
+`)
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		in, out, errout := prepare(preservesrc)
+		err := main.Add(main.NewFakeCloseReader(in), out, errout, `//options`, nil, "option", "", "", false, config)
+		gotwant.TestError(t, err, nil)
+		in, out2, errout := prepare(readAll(out))
+		err = main.Add(main.NewFakeCloseReader(in), out2, errout, `//option[@name='value']`, nil, "#text", "a & b", "", false, config)
+		gotwant.TestError(t, err, nil)
+		in, out3, errout := prepare(readAll(out2))
+		err = main.Add(main.NewFakeCloseReader(in), out3, errout, `//option[@name='xpath']`, nil, "#comment", "sibling", "", true, config)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, readAll(out3), `<?xml version='1.0' standalone="yes"?>
+<!-- header -->
+<xml>
+    <command name='add' >
+        <options>
+            <option name="xpath">
+                <desc>An XPath &amp; &#x41;</desc>
+            </option>
+            <!--sibling-->
+            <option name="name"></option>
+            <option name="value">a &amp; b</option>
+            <option/>
+        </options>
+    </command>
+</xml>
+`)
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		in, out, errout := prepare(xmlpi + `<root><hoge/></root>`)
+		err := main.Add(main.NewFakeCloseReader(in), out, errout, `/root/hoge`, nil, "a", "", "", false, config)
+		gotwant.TestError(t, err, nil)
+		gotwant.Test(t, readAll(out), xmlpi+`<root><hoge><a/></hoge></root>`)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		in, out, _ := prepare(`<root>`)
+		err := main.Delete(main.NewFakeCloseReader(in), out, io.Discard, `//nothing`, nil, config)
+		gotwant.Test(t, err != nil, true)
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type renameCmd struct {
+	_ struct{} `help:"eksemel rename --xpath //hoge --name fuga hoge.xml"`
+
+	XPath string `cli:"xpath" help:"elements or attributes" required:"true"`
+	Name  string `cli:"name" help:"new name, may be prefix:name with a prefix declared in the document or bound by --ns" required:"true"`
+
+	common
+}
+
+// Rename renames elements and attributes matched by xpath to name.
+//
+// If name has a prefix, it must be declared in the scope of the node or be bound in ns.
+// A prefix bound only in ns is declared on the element.
+func Rename(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, name string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	renameNodes(doc, errOutput, xpath, ns, name)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func renameNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string, name string) {
+	nodes, err := queryAll(doc, xpath, ns)
+	if err != nil {
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
+		return
+	}
+
+	for _, n := range nodes {
+		if err := renameNode(n, name, ns); err != nil {
+			fmt.Fprintf(errOutput, "name: %v\n", err)
+			return
+		}
+	}
+}
+
+// renameNode renames an element or an attribute n to name. See Rename.
+func renameNode(n *xmlquery.Node, name string, ns map[string]string) error {
+	prefix, local, found := strings.Cut(name, ":")
+	if !found {
+		prefix, local = "", name
+	}
+	if local == "" || strings.Contains(local, ":") || (found && prefix == "") {
+		return fmt.Errorf("%q is not a valid name", name)
+	}
+
+	switch n.Type {
+	case xmlquery.ElementNode:
+		uri, err := resolvePrefix(n, prefix, ns)
+		if err != nil {
+			return err
+		}
+		n.Prefix = prefix
+		n.Data = local
+		n.NamespaceURI = uri
+
+	case xmlquery.AttributeNode:
+		owner := n.Parent
+		i := findAttr(owner, n.Prefix, n.Data)
+		if i == -1 {
+			return nil
+		}
+
+		uri := ""
+		if prefix != "" {
+			var err error
+			uri, err = resolvePrefix(owner, prefix, ns)
+			if err != nil {
+				return err
+			}
+		}
+		owner.Attr[i].Name = xml.Name{Space: prefix, Local: local}
+		owner.Attr[i].NamespaceURI = uri
+
+	default:
+		return fmt.Errorf("%v is not an element or an attribute", n.Data)
+	}
+
+	return nil
+}
+
+// resolvePrefix returns the namespace URI of prefix in the scope of an element n.
+// If prefix is declared only in ns, it is declared on n.
+func resolvePrefix(n *xmlquery.Node, prefix string, ns map[string]string) (string, error) {
+	if prefix == "xml" {
+		return "http://www.w3.org/XML/1998/namespace", nil
+	}
+
+	if uri := lookupNamespace(n, prefix); uri != "" || prefix == "" {
+		return uri, nil
+	}
+
+	uri, found := ns[prefix]
+	if !found {
+		return "", fmt.Errorf("prefix %q is not bound", prefix)
+	}
+	n.Attr = append(n.Attr, xmlquery.Attr{
+		Name:  xml.Name{Space: "xmlns", Local: prefix},
+		Value: uri,
+	})
+	return uri, nil
+}
+
+func (c renameCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	renameNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.Name)
+	return nil
+}
+
+func (c renameCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Rename(input, w, os.Stderr, c.XPath, ns, c.Name, c.outputConfig(filename))
+	})
+}
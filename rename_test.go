@@ -0,0 +1,107 @@
+package main_test
+
+import (
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type renametestdata struct {
+	input string
+	xpath string
+	ns    map[string]string
+	name  string
+
+	err         error
+	out, errout string
+}
+
+func testrename(t *testing.T, data []renametestdata) {
+	t.Helper()
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+		err := main.Rename(
+			main.NewFakeCloseReader(in),
+			out,
+			errout,
+			d.xpath,
+			d.ns,
+			d.name,
+			main.OutputConfig{EmptyElement: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), d.errout, gotwant.Desc(seq))
+	}
+}
+
+func TestRename(t *testing.T) {
+	testrename(t, []renametestdata{
+		{
+			input: xmlpi + `<root><a id="1">text</a><a/></root>`,
+			xpath: `//a`,
+			name:  "b",
+			out:   xmlpi + `<root><b id="1">text</b><b/></root>`,
+		},
+		{
+			input: xmlpi + `<root><a id="1"/></root>`,
+			xpath: `//a/@id`,
+			name:  "key",
+			out:   xmlpi + `<root><a key="1"/></root>`,
+		},
+		{
+			// declared in the document
+			input: xmlpi + `<root xmlns:x="urn:x"><a/></root>`,
+			xpath: `//a`,
+			name:  "x:b",
+			out:   xmlpi + `<root xmlns:x="urn:x"><x:b/></root>`,
+		},
+		{
+			// bound by --ns
+			input: xmlpi + `<root><a id="1"/></root>`,
+			xpath: `//a/@id`,
+			ns:    map[string]string{"y": "urn:y"},
+			name:  "y:id",
+			out:   xmlpi + `<root><a y:id="1" xmlns:y="urn:y"/></root>`,
+		},
+		{
+			// a prefixed attribute, not the unprefixed one of the same local name
+			input: xmlpi + `<root xmlns:x="urn:x"><a id="1" x:id="2"/></root>`,
+			xpath: `//a/@x:id`,
+			name:  "x:key",
+			out:   xmlpi + `<root xmlns:x="urn:x"><a id="1" x:key="2"/></root>`,
+		},
+		{
+			input: xmlpi + `<root xmlns:x="urn:x"><a x:id="2" id="1"/></root>`,
+			xpath: `//a/@id`,
+			name:  "key",
+			out:   xmlpi + `<root xmlns:x="urn:x"><a x:id="2" key="1"/></root>`,
+		},
+		{
+			input: xmlpi + `<root xmlns:x="urn:x"><x:a/></root>`,
+			xpath: `//x:a`,
+			name:  "b",
+			out:   xmlpi + `<root xmlns:x="urn:x"><b/></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a/></root>`,
+			xpath:  `//a`,
+			name:   "z:b",
+			out:    xmlpi + `<root><a/></root>`,
+			errout: "name: prefix \"z\" is not bound\n",
+		},
+		{
+			input:  xmlpi + `<root><a/></root>`,
+			xpath:  `//a`,
+			name:   "b:",
+			out:    xmlpi + `<root><a/></root>`,
+			errout: "name: \"b:\" is not a valid name\n",
+		},
+	})
+}
@@ -10,10 +10,11 @@ import (
 )
 
 type replacetestdata struct {
-	input string
-	xpath string
-	value string
-	ennet string
+	input  string
+	xpath  string
+	value  string
+	ennet  string
+	target string
 
 	indent int
 
@@ -31,8 +32,10 @@ func testreplace(t *testing.T, data []replacetestdata) {
 			out,
 			errout,
 			d.xpath,
+			nil,
 			d.value,
 			d.ennet,
+			d.target,
 			main.OutputConfig{Indent: strings.Repeat(" ", d.indent), EmptyElement: true},
 		)
 
@@ -82,5 +85,80 @@ func TestReplace(t *testing.T) {
 			value: "komento",
 			out:   xmlpi + `<root><!--komento--></root>`,
 		},
+		{
+			input: xmlpi + `<root xmlns:x="urn:x"><a id="1" x:id="2"/></root>`,
+			xpath: `//@x:id`,
+			value: "9",
+			out:   xmlpi + `<root xmlns:x="urn:x"><a id="1" x:id="9"/></root>`,
+		},
+	})
+}
+
+func TestReplaceTarget(t *testing.T) {
+	testreplace(t, []replacetestdata{
+		{
+			input:  xmlpi + `<root><a>old<b/></a><a/></root>`,
+			xpath:  `//a`,
+			value:  "new & text",
+			target: main.TargetText,
+			out:    xmlpi + `<root><a>new &amp; text</a><a>new &amp; text</a></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a id="1"/><!--comment--></root>`,
+			xpath:  `//@id | //comment()`,
+			value:  "2",
+			target: main.TargetText,
+			out:    xmlpi + `<root><a id="2"/><!--2--></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a>old</a></root>`,
+			xpath:  `//a`,
+			target: main.TargetText,
+			out:    xmlpi + `<root><a/></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a id="1">text</a></root>`,
+			xpath:  `//a`,
+			value:  "b",
+			target: main.TargetName,
+			out:    xmlpi + `<root><b id="1">text</b></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a>text</a></root>`,
+			xpath:  `//a/text()`,
+			value:  "b",
+			target: main.TargetName,
+			out:    xmlpi + `<root><a>text</a></root>`,
+			errout: "value: text is not an element or an attribute\n",
+		},
+		{
+			input:  xmlpi + `<root><a>old</a><c/></root>`,
+			xpath:  `//a`,
+			value:  `<b x="1">new</b>tail`,
+			target: main.TargetOuter,
+			out:    xmlpi + `<root><b x="1">new</b>tail<c/></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a>old<x/></a></root>`,
+			xpath:  `//a`,
+			value:  `<b/>new`,
+			target: main.TargetInner,
+			out:    xmlpi + `<root><a><b/>new</a></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a>old</a></root>`,
+			xpath:  `//a`,
+			ennet:  `b+c`,
+			target: main.TargetInner,
+			out:    xmlpi + `<root><a><b/><c/></a></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a>old</a></root>`,
+			xpath:  `//a`,
+			value:  `<b>`,
+			target: main.TargetOuter,
+			out:    xmlpi + `<root><a>old</a></root>`,
+			errout: "value: XML syntax error on line 1: element <b> closed by </_>\n",
+		},
 	})
 }
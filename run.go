@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/shu-go/gli/v2"
+)
+
+type runCmd struct {
+	_ struct{} `help:"eksemel run script.txt hoge.xml" usage:"A script has a command per line:\n  # comment\n  add --xpath \"//command[@name='add']\" --name desc --value 'it''s added'\n  delete --xpath //dummy\n  get --xpath //desc/text() --multiple"`
+
+	Print bool `cli:"print" default:"true" help:"output the resulting document"`
+
+	common
+}
+
+// scriptCmd is a set of commands available in a script.
+type scriptCmd struct {
+	Replace replaceCmd
+	Delete  deleteCmd
+	Add     addCmd
+	Move    moveCmd
+	Copy    copyCmd
+	Rename  renameCmd
+	Wrap    wrapCmd
+	Unwrap  unwrapCmd
+	Sort    sortCmd
+	Patch   patchCmd
+
+	Get getCmd
+}
+
+// docOperation is a command applicable to a parsed document.
+// output receives results of non-mutating commands like get.
+type docOperation interface {
+	apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error
+}
+
+type scriptLine struct {
+	op   docOperation
+	line int
+}
+
+func (c runCmd) Before() error {
+	if !c.Print && (c.InPlace || c.Backup != "" || c.Output != "" || c.Validate != "") {
+		return errors.New("--in-place, --backup, -o and --validate are not available with --no-print")
+	}
+	return nil
+}
+
+func (c runCmd) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("script required")
+	}
+
+	script, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if !c.Print {
+		return RunScript(input, bytes.NewReader(script), ns, nil, os.Stdout, os.Stderr, c.outputConfig(filename))
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return RunScript(input, bytes.NewReader(script), ns, w, os.Stdout, os.Stderr, c.outputConfig(filename))
+	})
+}
+
+// RunScript parses input once, applies the commands in script in order, and writes the document to output.
+// Results of get commands are written to resultOutput.
+// If output is nil, the document is not written.
+// ns is namespace bindings for all the commands.
+func RunScript(input io.ReadCloser, script io.Reader, ns map[string]string, output, resultOutput, errOutput io.Writer, config OutputConfig) error {
+	lines, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		if err := l.op.apply(doc, ns, resultOutput, errOutput); err != nil {
+			return fmt.Errorf("script line %d: %w", l.line, err)
+		}
+	}
+
+	if output != nil {
+		OutputXML(output, doc, config)
+	}
+
+	return nil
+}
+
+func parseScript(script io.Reader) ([]scriptLine, error) {
+	var lines []scriptLine
+
+	s := bufio.NewScanner(script)
+	s.Buffer(nil, 1024*1024)
+	lineno := 0
+	for s.Scan() {
+		lineno++
+
+		words, err := splitScriptLine(s.Text())
+		if err != nil {
+			return nil, fmt.Errorf("script line %d: %w", lineno, err)
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		op, err := parseScriptCommand(words)
+		if err != nil {
+			return nil, fmt.Errorf("script line %d: %w", lineno, err)
+		}
+		lines = append(lines, scriptLine{op: op, line: lineno})
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("script: %w", err)
+	}
+
+	return lines, nil
+}
+
+func parseScriptCommand(words []string) (docOperation, error) {
+	app := gli.NewWith(&scriptCmd{})
+	app.SuppressErrorOutput = true
+
+	args, values := scriptArgs(words)
+
+	tgt, rest, err := app.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := tgt.(docOperation)
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q", words[0])
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected arguments %q", rest)
+	}
+
+	v := reflect.ValueOf(tgt).Elem()
+	for _, sv := range values {
+		f := v.FieldByIndex(sv.index)
+		if f.Kind() == reflect.Slice {
+			f.Set(reflect.ValueOf(sv.values))
+		} else {
+			f.SetString(sv.values[len(sv.values)-1])
+		}
+	}
+
+	if name := outputOption(v); name != "" {
+		return nil, fmt.Errorf("%s is not available in scripts", name)
+	}
+
+	if b, ok := tgt.(interface{ Before() error }); ok {
+		if err := b.Before(); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
+
+// scriptValue is the values of a string option in a script line, set after gli parses the line.
+type scriptValue struct {
+	index  []int
+	values []string
+}
+
+// scriptArgs converts words of a script line into arguments for gli.
+//
+// The parser of gli splits a word at '=' and takes '"' as a quote, which are common in XPath.
+// So string options (string and List) are given to gli with empty values, and their values are returned to be set after parsing.
+// Other options (bool, int, Choice, ...) are given as they are.
+func scriptArgs(words []string) ([]string, []scriptValue) {
+	cmd, found := scriptCommandType(words[0])
+	if !found {
+		return words, nil
+	}
+
+	args := []string{words[0]}
+	var values []scriptValue
+	byField := make(map[string]int) // index of values by the index of the field
+
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		if !strings.HasPrefix(w, "-") {
+			args = append(args, w)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(w, "=")
+		f, found := scriptOptionField(cmd, strings.TrimLeft(name, "-"))
+		if !found || f.Type.Kind() == reflect.Bool {
+			args = append(args, w)
+			continue
+		}
+
+		if !hasValue {
+			if i+1 == len(words) {
+				// no value; gli tells it
+				args = append(args, w)
+				continue
+			}
+			i++
+			value = words[i]
+		}
+
+		if !isStringOption(f) {
+			args = append(args, name, value)
+			continue
+		}
+
+		key := fmt.Sprint(f.Index)
+		if j, found := byField[key]; found {
+			values[j].values = append(values[j].values, value)
+		} else {
+			byField[key] = len(values)
+			values = append(values, scriptValue{index: f.Index, values: []string{value}})
+		}
+		args = append(args, name, `""`)
+	}
+
+	return args, values
+}
+
+// scriptCommandType returns the type of the command name in scriptCmd.
+func scriptCommandType(name string) (reflect.Type, bool) {
+	t := reflect.TypeOf(scriptCmd{})
+	for i := 0; i < t.NumField(); i++ {
+		if strings.ToLower(t.Field(i).Name) == name {
+			return t.Field(i).Type, true
+		}
+	}
+	return nil, false
+}
+
+// scriptOptionField returns the field of the option name in the command type t, including ones in embedded structs like common.
+// The index of the field is from t.
+func scriptOptionField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if ef, found := scriptOptionField(f.Type, name); found {
+				ef.Index = append([]int{i}, ef.Index...)
+				return ef, true
+			}
+			continue
+		}
+
+		names, _, _ := strings.Cut(f.Tag.Get("cli"), "=")
+		for _, n := range strings.Split(names, ",") {
+			if n == name {
+				return f, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// isStringOption reports whether the option f takes any string as it is.
+func isStringOption(f reflect.StructField) bool {
+	switch typ := f.Tag.Get("type"); {
+	case f.Type.Kind() == reflect.String:
+		return typ == ""
+	case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+		return typ == "List"
+	}
+	return false
+}
+
+// outputOption returns the name of an option in common given to the command cmd, other than --ns.
+// Such options apply to the output of run as a whole, not to a line of a script.
+func outputOption(cmd reflect.Value) string {
+	c := reflect.Indirect(cmd).FieldByName("common")
+	if !c.IsValid() {
+		return ""
+	}
+
+	for i := 0; i < c.NumField(); i++ {
+		f, v := c.Type().Field(i), c.Field(i)
+		if f.Name == "Namespaces" {
+			continue
+		}
+
+		given := !v.IsZero()
+		if def, found := f.Tag.Lookup("default"); found {
+			switch v.Kind() {
+			case reflect.Bool:
+				given = strconv.FormatBool(v.Bool()) != def
+			case reflect.Int:
+				given = strconv.FormatInt(v.Int(), 10) != def
+			case reflect.String:
+				given = v.String() != def
+			}
+		}
+		if given {
+			name, _, _ := strings.Cut(f.Tag.Get("cli"), "=")
+			name, _, _ = strings.Cut(name, ",")
+			return "--" + name
+		}
+	}
+	return ""
+}
+
+// splitScriptLine splits a line into words separated by spaces.
+//
+// A word may be quoted with "..." or '...', where the quote character doubled is the character itself.
+// Backslashes are not special.
+// A line beginning with # is a comment.
+func splitScriptLine(line string) ([]string, error) {
+	var words []string
+
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if quote != 0 {
+			if r == quote {
+				if i+1 < len(runes) && runes[i+1] == quote {
+					word.WriteRune(r)
+					i++
+				} else {
+					quote = 0
+				}
+			} else {
+				word.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '#' && !inWord && len(words) == 0:
+			return words, nil
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote %c", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
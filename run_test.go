@@ -0,0 +1,150 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type runtestdata struct {
+	input  string
+	script string
+
+	indent int
+
+	err            string
+	out, resultout string
+}
+
+func testrun(t *testing.T, data []runtestdata) {
+	t.Helper()
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+		resultout := &bytes.Buffer{}
+		err := main.RunScript(
+			main.NewFakeCloseReader(in),
+			strings.NewReader(d.script),
+			nil,
+			out,
+			resultout,
+			errout,
+			main.OutputConfig{Indent: strings.Repeat(" ", d.indent), EmptyElement: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		if d.err != "" {
+			if err == nil || !strings.Contains(err.Error(), d.err) {
+				t.Errorf("%s: err=%v, want %q", seq, err, d.err)
+			}
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, resultout.String(), d.resultout, gotwant.Desc(seq))
+	}
+}
+
+func TestRunAddedElement(t *testing.T) {
+	testrun(t, []runtestdata{
+		{
+			// an added element is matched by later commands
+			input:     xmlpi + `<root/>`,
+			script:    "add --xpath /root --name a\nadd --xpath //a --name b\nget --xpath //b\n",
+			out:       xmlpi + `<root><a><b/></a></root>`,
+			resultout: "b\n",
+		},
+	})
+}
+
+func TestRun(t *testing.T) {
+	testrun(t, []runtestdata{
+		{
+			input:  xmlpi + `<root><hoge/></root>`,
+			script: ``,
+			out:    xmlpi + `<root><hoge/></root>`,
+		},
+		{
+			input: xmlpi + `<root><hoge/><fuga/></root>`,
+			script: `
+# comment
+add --xpath /root/hoge --name a --value "a ""text"""
+  add --xpath /root/hoge --name '#comment' --value 'it''s a comment'
+delete --xpath //fuga
+replace --xpath /root --value loot
+`,
+			out: xmlpi + `<loot><hoge><a>a "text"</a><!--it's a comment--></hoge></loot>`,
+		},
+		{
+			input: xmlpi + `<root><hoge>1</hoge><hoge>2</hoge></root>`,
+			script: `get --xpath //hoge/text() --multiple --sep ,
+delete --xpath "//hoge[text()='1']"
+get --xpath //hoge/text() --multiple
+add --xpath '//hoge[.="2"]' --name @a --value=x=y
+add --xpath "//hoge[@a='x=y']" --name b --value '"quoted"'`,
+			out:       xmlpi + `<root><hoge a="x=y">2<b>"quoted"</b></hoge></root>`,
+			resultout: "1,2\n2\n",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "add --xpath /root\n",
+			err:    "script line 1: either --name or --ennet is required",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "\nmove --xpath /root\n",
+			err:    "script line 2:",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "delete --xpath '/root\n",
+			err:    "script line 1: unclosed quote '",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "delete --xpath /root extra\n",
+			err:    "script line 1: unexpected arguments",
+		},
+		{
+			input:  xmlpi + `<x:root xmlns:x="urn:x"><x:a/></x:root>`,
+			script: "delete --xpath //y:a --ns y=urn:x\n",
+			out:    xmlpi + `<x:root xmlns:x="urn:x"/>`,
+		},
+		{
+			// values with = and " are taken as they are, as well as ones beginning with -
+			input:  xmlpi + `<root><a k="a" x="1"/><a k="b" x="2"/></root>`,
+			script: `replace --xpath '//a[@k="a"]/@x' --value '="q"'` + "\n" + `replace --xpath='//a[@k="b"]/@x' --value=-1` + "\n",
+			out:    xmlpi + `<root><a k="a" x="=&quot;q&quot;"/><a k="b" x="-1"/></root>`,
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "delete --xpath //a\ndelete --xpath /root --in-place\n",
+			err:    "script line 2: --in-place is not available in scripts",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "add --xpath /root --name a -o out.xml\n",
+			err:    "script line 1: --output is not available in scripts",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "rename --xpath /root --name r --indent 2\n",
+			err:    "script line 1: --indent is not available in scripts",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "sort --xpath /root --escape ascii\n",
+			err:    "script line 1: --escape is not available in scripts",
+		},
+		{
+			input:  xmlpi + `<root/>`,
+			script: "delete --xpath /root --validate schema.xsd\n",
+			err:    "script line 1: --validate is not available in scripts",
+		},
+	})
+}
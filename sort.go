@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+type sortCmd struct {
+	_ struct{} `help:"eksemel sort --xpath //options --by @name hoge.xml"`
+
+	XPath string `cli:"xpath" help:"parents whose element children are sorted" required:"true"`
+	By    string `cli:"by" help:"key relative to each child (default: the name of the child)"`
+
+	Numeric bool `cli:"numeric" help:"compare keys as numbers"`
+	Reverse bool `cli:"reverse" help:"sort in descending order"`
+	Stable  bool `cli:"stable" help:"keep the order of children with equal keys"`
+
+	common
+}
+
+// SortOptions tells how to sort children.
+type SortOptions struct {
+	// By is an XPath relative to each child. If empty, the name of the child is the key.
+	By string
+
+	Numeric bool
+	Reverse bool
+	Stable  bool
+}
+
+// Sort reorders element children of each element matched by xpath.
+//
+// Nodes before an element (comments, whitespace, texts) move together with the element.
+// Nodes after the last element stay at the end.
+func Sort(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, opts SortOptions, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	sortNodes(doc, errOutput, xpath, ns, opts)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+// sortUnit is an element child and the nodes attached before it.
+type sortUnit struct {
+	nodes []*xmlquery.Node
+	key   string
+	num   float64
+	isNum bool
+}
+
+func sortNodes(doc *xmlquery.Node, errOutput io.Writer, expr string, ns map[string]string, opts SortOptions) {
+	parents, err := queryAll(doc, expr, ns)
+	if err != nil {
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
+		return
+	}
+
+	var by *xpath.Expr
+	if opts.By != "" {
+		by, err = compileXPath(doc, opts.By, ns)
+		if err != nil {
+			fmt.Fprintf(errOutput, "by: %v\n", err)
+			return
+		}
+	}
+
+	for _, p := range parents {
+		if p.Type != xmlquery.ElementNode {
+			fmt.Fprintf(errOutput, "xpath: %v is not an element node\n", p.Data)
+			continue
+		}
+
+		var units []sortUnit
+		var pending []*xmlquery.Node
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			pending = append(pending, c)
+			if c.Type != xmlquery.ElementNode {
+				continue
+			}
+
+			u := sortUnit{nodes: pending}
+			if by != nil {
+				u.key = evaluateString(c, by)
+			} else {
+				u.key = nodeName(c)
+			}
+			if opts.Numeric {
+				u.num, err = strconv.ParseFloat(strings.TrimSpace(u.key), 64)
+				u.isNum = err == nil
+			}
+			units = append(units, u)
+			pending = nil
+		}
+
+		less := func(i, j int) bool {
+			a, b := units[i], units[j]
+			if opts.Reverse {
+				a, b = b, a
+			}
+			if opts.Numeric && (a.isNum || b.isNum) {
+				// numbers first
+				if a.isNum != b.isNum {
+					return a.isNum != opts.Reverse
+				}
+				return a.num < b.num
+			}
+			return a.key < b.key
+		}
+		if opts.Stable {
+			sort.SliceStable(units, less)
+		} else {
+			sort.Slice(units, less)
+		}
+
+		for c := p.FirstChild; c != nil; c = p.FirstChild {
+			xmlquery.RemoveFromTree(c)
+		}
+		for _, u := range units {
+			for _, n := range u.nodes {
+				InsertNode(p, n, PositionLastChild)
+			}
+		}
+		for _, n := range pending {
+			InsertNode(p, n, PositionLastChild)
+		}
+	}
+}
+
+func (c sortCmd) options() SortOptions {
+	return SortOptions{
+		By:      c.By,
+		Numeric: c.Numeric,
+		Reverse: c.Reverse,
+		Stable:  c.Stable,
+	}
+}
+
+func (c sortCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	sortNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.options())
+	return nil
+}
+
+func (c sortCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Sort(input, w, os.Stderr, c.XPath, ns, c.options(), c.outputConfig(filename))
+	})
+}
@@ -0,0 +1,99 @@
+package main_test
+
+import (
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type sorttestdata struct {
+	input string
+	xpath string
+	opts  main.SortOptions
+
+	preserve bool
+
+	err         error
+	out, errout string
+}
+
+func TestSort(t *testing.T) {
+	data := []sorttestdata{
+		{
+			input: xmlpi + `<root><c/><a/><b/></root>`,
+			xpath: `/root`,
+			out:   xmlpi + `<root><a/><b/><c/></root>`,
+		},
+		{
+			input: xmlpi + `<root><o name="b"/><o name="c"/><o name="a"/></root>`,
+			xpath: `/root`,
+			opts:  main.SortOptions{By: `@name`, Reverse: true},
+			out:   xmlpi + `<root><o name="c"/><o name="b"/><o name="a"/></root>`,
+		},
+		{
+			input: xmlpi + `<root><o n="10"/><o n="x"/><o n="9"/><o n="-1"/></root>`,
+			xpath: `/root`,
+			opts:  main.SortOptions{By: `@n`, Numeric: true},
+			out:   xmlpi + `<root><o n="-1"/><o n="9"/><o n="10"/><o n="x"/></root>`,
+		},
+		{
+			input: xmlpi + `<root><o n="10"/><o n="x"/><o n="9"/><o n="-1"/></root>`,
+			xpath: `/root`,
+			opts:  main.SortOptions{By: `@n`, Numeric: true, Reverse: true},
+			out:   xmlpi + `<root><o n="10"/><o n="9"/><o n="-1"/><o n="x"/></root>`,
+		},
+		{
+			input: xmlpi + `<root><o k="1" id="a"/><o k="0" id="b"/><o k="1" id="c"/><o k="0" id="d"/></root>`,
+			xpath: `/root`,
+			opts:  main.SortOptions{By: `@k`, Stable: true},
+			out:   xmlpi + `<root><o k="0" id="b"/><o k="0" id="d"/><o k="1" id="a"/><o k="1" id="c"/></root>`,
+		},
+		{
+			input: xmlpi + `<root><o k="1" id="a"/><o k="0" id="b"/><o k="1" id="c"/><o k="0" id="d"/></root>`,
+			xpath: `/root`,
+			opts:  main.SortOptions{By: `@k`, Stable: true, Reverse: true},
+			out:   xmlpi + `<root><o k="1" id="a"/><o k="1" id="c"/><o k="0" id="b"/><o k="0" id="d"/></root>`,
+		},
+		{
+			// comments go with the following element
+			input: xmlpi + `<root><list><!--b--><b/><!--a--><a/><!--end--></list><list><y><z/></y><x/></list></root>`,
+			xpath: `//list`,
+			out:   xmlpi + `<root><list><!--a--><a/><!--b--><b/><!--end--></list><list><x/><y><z/></y></list></root>`,
+		},
+		{
+			input:    xmlpi + "<root>\n  <!-- B -->\n  <b>2</b>\n  <a>1</a>\n</root>",
+			xpath:    `/root`,
+			opts:     main.SortOptions{By: `text()`},
+			preserve: true,
+			out:      xmlpi + "<root>\n  <a>1</a>\n  <!-- B -->\n  <b>2</b>\n</root>",
+		},
+		{
+			input:  xmlpi + `<root><b/><a/></root>`,
+			xpath:  `/root`,
+			opts:   main.SortOptions{By: `@`},
+			out:    xmlpi + `<root><b/><a/></root>`,
+			errout: "by: expression must evaluate to a node-set\n",
+		},
+	}
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+		err := main.Sort(
+			main.NewFakeCloseReader(in),
+			out,
+			errout,
+			d.xpath,
+			nil,
+			d.opts,
+			main.OutputConfig{EmptyElement: true, Preserve: d.preserve},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), d.errout, gotwant.Desc(seq))
+	}
+}
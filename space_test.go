@@ -0,0 +1,116 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestPreserveSpace(t *testing.T) {
+	const src = xmlpi + `<doc><p>Hello <b>world</b>!</p><pre xml:space="preserve">
+  a  <i> b </i>
+</pre><code>  x  </code><list>  <item> 1 </item>  </list><d/></doc>`
+
+	data := []struct {
+		preserveSpace []string
+		out           string
+	}{
+		{
+			out: xmlpi + `
+<doc>
+  <p>Hello <b>world</b>!</p>
+  <pre xml:space="preserve">
+  a  <i> b </i>
+</pre>
+  <code>x</code>
+  <list>
+    <item>1</item>
+  </list>
+</doc>
+`,
+		},
+		{
+			preserveSpace: []string{"//code", "//list"},
+			out: xmlpi + `
+<doc>
+  <p>Hello <b>world</b>!</p>
+  <pre xml:space="preserve">
+  a  <i> b </i>
+</pre>
+  <code>  x  </code>
+  <list>  <item> 1 </item>  </list>
+</doc>
+`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		out := &bytes.Buffer{}
+		err := main.Delete(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, &bytes.Buffer{}, `//d`, nil, main.OutputConfig{Indent: "  ", EmptyElement: true, PreserveSpace: d.preserveSpace})
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestPreserveSpaceNamespaces(t *testing.T) {
+	const src = xmlpi + `<doc xmlns="urn:m" xmlns:x="urn:x"><pre>  a  </pre></doc>`
+
+	out := &bytes.Buffer{}
+	err := main.Format(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, main.OutputConfig{Indent: "  ", EmptyElement: true, PreserveSpace: []string{"//m:pre"}, Namespaces: map[string]string{"m": "urn:m"}})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), xmlpi+`
+<doc xmlns="urn:m" xmlns:x="urn:x">
+  <pre>  a  </pre>
+</doc>
+`)
+
+	out.Reset()
+	err = main.Format(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, main.OutputConfig{Indent: "  ", EmptyElement: true, PreserveSpace: []string{"//m:pre"}})
+	gotwant.Test(t, err.Error(), "preserve-space: prefix m not defined.")
+}
+
+func TestPreserveSpaceDefault(t *testing.T) {
+	const src = xmlpi + `<doc><pre xml:space="preserve">
+  a  <note xml:space="default">
+    <p>  b  </p>
+    <q xml:space="preserve"> c </q>
+  </note>  d
+</pre><code xml:space="default">  x  </code></doc>`
+
+	out := &bytes.Buffer{}
+	err := main.Format(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, main.OutputConfig{Indent: "  ", EmptyElement: true, PreserveSpace: []string{"//code"}})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), xmlpi+`
+<doc>
+  <pre xml:space="preserve">
+  a  <note xml:space="default"><p>b</p><q xml:space="preserve"> c </q></note>  d
+</pre>
+  <code xml:space="default">x</code>
+</doc>
+`)
+}
+
+func TestPreserveSpaceInline(t *testing.T) {
+	const src = xmlpi + `<doc><p><b>a</b> <i>b</i></p><list>
+<a/>
+<b/>
+</list></doc>`
+
+	out := &bytes.Buffer{}
+	err := main.Format(main.NewFakeCloseReader(bytes.NewBufferString(src)), out, main.OutputConfig{Indent: "  ", EmptyElement: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), xmlpi+`
+<doc>
+  <p><b>a</b> <i>b</i></p>
+  <list>
+    <a/>
+    <b/>
+  </list>
+</doc>
+`)
+}
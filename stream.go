@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// streamPath is an XPath that can be evaluated while reading a document:
+// element names from the root with / or //, a predicate on the last element,
+// and optionally a relative path within the element after it (e.g. /@attr, /text() or /child).
+type streamPath struct {
+	steps     []streamStep
+	predicate string
+	tail      string
+}
+
+type streamStep struct {
+	descendant bool
+	name       string
+}
+
+// look-ahead or context outside the element
+var reStreamUnsupported = regexp.MustCompile(`following|preceding|ancestor|parent::|\.\.|last\(\)|position\(\)|^\s*\d+\s*$|^\s*/`)
+
+// parseStreamPath parses expr into a streamPath, or returns an error telling why it cannot be streamed.
+func parseStreamPath(expr string) (*streamPath, error) {
+	if !strings.HasPrefix(expr, "/") {
+		return nil, fmt.Errorf("stream: %q must be an absolute path", expr)
+	}
+
+	segments, err := splitXPathSteps(expr[1:])
+	if err != nil {
+		return nil, fmt.Errorf("stream: %q: %v", expr, err)
+	}
+
+	sp := &streamPath{}
+	descendant := false
+	for i, seg := range segments {
+		if seg == "" {
+			if descendant {
+				return nil, fmt.Errorf("stream: %q is not a valid path", expr)
+			}
+			descendant = true
+			continue
+		}
+
+		name, preds, err := splitXPathPredicates(seg)
+		if err != nil {
+			return nil, fmt.Errorf("stream: %q: %v", expr, err)
+		}
+		// chained predicates are applied one after another, which is the same as
+		// joining them while none of them depends on the position
+		pred := strings.Join(preds, "][")
+
+		if sp.predicate != "" || !reQName.MatchString(name) && name != "*" {
+			if len(sp.steps) == 0 {
+				return nil, fmt.Errorf("stream: %q cannot be streamed (only element names, with a predicate on the last one, are allowed in the path)", expr)
+			}
+
+			// relative to the last element: /@attr, /text(), after a predicate, and so on
+			sp.tail = strings.Join(segments[i:], "/")
+			if descendant {
+				sp.tail = ".//" + sp.tail
+			}
+			descendant = false
+			break
+		}
+		for _, p := range preds {
+			if reStreamUnsupported.MatchString(p) {
+				return nil, fmt.Errorf("stream: %q cannot be streamed (the predicate [%s] requires look-ahead or elements outside the matched one)", expr, p)
+			}
+		}
+
+		sp.steps = append(sp.steps, streamStep{descendant: descendant, name: name})
+		sp.predicate = pred
+		descendant = false
+	}
+
+	if len(sp.steps) == 0 || descendant {
+		return nil, fmt.Errorf("stream: %q is not a valid path", expr)
+	}
+	if reStreamUnsupported.MatchString(sp.tail) {
+		return nil, fmt.Errorf("stream: %q cannot be streamed (%s requires look-ahead or elements outside the matched one)", expr, sp.tail)
+	}
+
+	return sp, nil
+}
+
+// splitXPathSteps splits expr by / outside predicates and quotes.
+func splitXPathSteps(expr string) ([]string, error) {
+	var segments []string
+	depth := 0
+	var quote rune
+	start := 0
+	for i, c := range expr {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			segments = append(segments, expr[start:i])
+			start = i + 1
+		}
+	}
+	if depth != 0 || quote != 0 {
+		return nil, errors.New("unbalanced brackets or quotes")
+	}
+	return append(segments, expr[start:]), nil
+}
+
+// splitXPathPredicates splits a step into its name and the predicates following it.
+func splitXPathPredicates(step string) (string, []string, error) {
+	name, rest, found := strings.Cut(step, "[")
+	if !found {
+		return name, nil, nil
+	}
+
+	var preds []string
+	depth := 1
+	var quote rune
+	start := 0
+	for i, c := range rest {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				preds = append(preds, rest[start:i])
+			}
+		case depth == 0:
+			return "", nil, fmt.Errorf("unexpected %q after a predicate", rest[i:])
+		}
+	}
+	if depth != 0 || quote != 0 {
+		return "", nil, errors.New("unbalanced brackets or quotes")
+	}
+	return name, preds, nil
+}
+
+// elementPath returns the path to elements, without the predicate.
+func (sp *streamPath) elementPath() string {
+	var b strings.Builder
+	for _, s := range sp.steps {
+		if s.descendant {
+			b.WriteString("//")
+		} else {
+			b.WriteString("/")
+		}
+		b.WriteString(s.name)
+	}
+	return b.String()
+}
+
+// matches reports whether the element path names (from the root) matches the steps.
+func (sp *streamPath) matches(names []string) bool {
+	return matchStreamSteps(sp.steps, names)
+}
+
+func matchStreamSteps(steps []streamStep, names []string) bool {
+	if len(steps) == 0 {
+		return len(names) == 0
+	}
+
+	s := steps[len(steps)-1]
+	if len(names) == 0 || !s.matches(names[len(names)-1]) {
+		return false
+	}
+
+	steps, names = steps[:len(steps)-1], names[:len(names)-1]
+	if !s.descendant {
+		return matchStreamSteps(steps, names)
+	}
+	for i := len(names); i >= 0; i-- {
+		if matchStreamSteps(steps, names[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches compares by the local name if the step has no prefix, or by the prefix and the local name.
+func (s streamStep) matches(name string) bool {
+	if s.name == "*" || s.name == name {
+		return true
+	}
+	if !strings.Contains(s.name, ":") {
+		_, local, found := strings.Cut(name, ":")
+		return found && local == s.name
+	}
+	return false
+}
+
+// StreamGet is Get reading input element by element, by xmlquery.StreamParser.
+// expr must be streamable (see parseStreamPath), and only formats raw, text, outer-xml and inner-xml are available.
+// Prefixes in the element path are compared as they are; ns is for the predicate and the rest.
+func StreamGet(input io.ReadCloser, output io.Writer, expr string, ns map[string]string, multiple bool, sep, format string, exitStatus bool, config OutputConfig) error {
+	defer input.Close()
+
+	switch format {
+	case "", "raw", "text", "outer-xml", "inner-xml":
+	default:
+		return fmt.Errorf("stream: format %q is not available", format)
+	}
+
+	sp, err := parseStreamPath(expr)
+	if err != nil {
+		return err
+	}
+
+	// The predicate is not given to the parser as a filter;
+	// it would keep unmatched siblings in the tree until the next match, and slow down each step.
+	decoded, _, err := decodeInput(input)
+	if err != nil {
+		return fmt.Errorf("input: %w", err)
+	}
+
+	parser, err := xmlquery.CreateStreamParserWithOptions(decoded, xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{Strict: true, CharsetReader: decodedCharsetReader},
+	}, sp.elementPath())
+	if err != nil {
+		return fmt.Errorf("stream: %v", err)
+	}
+
+	b := bufio.NewWriter(output)
+	defer b.Flush()
+
+	count := 0
+	for {
+		elem, err := parser.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+
+		if sp.predicate != "" {
+			matched, err := queryAll(elem, "self::*["+sp.predicate+"]", ns)
+			if err != nil {
+				return fmt.Errorf("xpath: %v", err)
+			}
+			if len(matched) == 0 {
+				continue
+			}
+		}
+
+		nodes := []*xmlquery.Node{elem}
+		if sp.tail != "" {
+			nodes, err = queryAll(elem, sp.tail, ns)
+			if err != nil {
+				return fmt.Errorf("xpath: %v", err)
+			}
+		}
+
+		for _, n := range nodes {
+			if count > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(nodeString(n, format, config))
+			count++
+			if !multiple {
+				break
+			}
+		}
+		if !multiple && count > 0 {
+			break
+		}
+	}
+	b.WriteByte('\n')
+
+	if exitStatus && count == 0 {
+		return ErrFalse
+	}
+	return nil
+}
+
+// streamEdit copies input to output, and calls edit with each element matched by sp instead of copying it.
+// edit is given a document with the element in a wrapper <_>, and the wrapper's children are written.
+//
+// Unlike StreamGet, the source text of unmatched parts is copied as it is, which xmlquery.StreamParser does not keep.
+// Elements in a matched element are not matched.
+func streamEdit(input io.ReadCloser, output io.Writer, sp *streamPath, ns map[string]string, edit func(doc *xmlquery.Node) error) error {
+	defer input.Close()
+
+	decoded, te, err := decodeInput(input)
+	if err != nil {
+		return fmt.Errorf("input: %w", err)
+	}
+	r := &recordingReader{r: bufio.NewReader(decoded)}
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = decodedCharsetReader
+
+	// in the encoding of the input
+	w, closeEncoder := encodeOutput(output, te)
+	defer closeEncoder()
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	var names []string
+	var decls [][]xml.Attr // namespace declarations of each open element
+	var pending []byte     // whitespace, written unless followed by a deleted element
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			b.Write(pending)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+		raw := r.take(dec.InputOffset())
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			names = append(names, qualifiedName(tok.Name))
+			var nsattrs []xml.Attr
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					nsattrs = append(nsattrs, attr)
+				}
+			}
+			decls = append(decls, nsattrs)
+
+			if !sp.matches(names) {
+				break
+			}
+
+			// the whole element
+			elem := bytes.NewBuffer(raw)
+			for depth := 1; depth > 0; {
+				tok, err := dec.RawToken()
+				if err != nil {
+					return fmt.Errorf("input: %w", err)
+				}
+				switch tok.(type) {
+				case xml.StartElement:
+					depth++
+				case xml.EndElement:
+					depth--
+				}
+				elem.Write(r.take(dec.InputOffset()))
+			}
+			names = names[:len(names)-1]
+			ancestors := decls[:len(decls)-1]
+			decls = ancestors
+
+			var wrapper strings.Builder
+			wrapper.WriteString("<_")
+			for _, nsattrs := range ancestors {
+				for _, attr := range nsattrs {
+					fmt.Fprintf(&wrapper, ` %s=%q`, qualifiedName(attr.Name), attr.Value)
+				}
+			}
+			wrapper.WriteString(">")
+
+			doc, err := xmlquery.Parse(io.MultiReader(strings.NewReader(wrapper.String()), bytes.NewReader(elem.Bytes()), strings.NewReader("</_>")))
+			if err != nil {
+				return fmt.Errorf("input: %w", err)
+			}
+			w := rootElement(doc)
+
+			if sp.predicate != "" {
+				matched, err := queryAll(doc, "/_/*["+sp.predicate+"]", ns)
+				if err != nil {
+					return fmt.Errorf("xpath: %v", err)
+				}
+				if len(matched) == 0 {
+					b.Write(pending)
+					pending = nil
+					b.Write(elem.Bytes())
+					continue
+				}
+			}
+
+			if err := edit(doc); err != nil {
+				return err
+			}
+
+			if w.FirstChild != nil {
+				b.Write(pending)
+			}
+			pending = nil
+			for c := w.FirstChild; c != nil; c = c.NextSibling {
+				OutputXML(b, c, OutputConfig{EmptyElement: true})
+			}
+			continue
+
+		case xml.EndElement:
+			names = names[:len(names)-1]
+			decls = decls[:len(decls)-1]
+
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) == 0 {
+				pending = append(pending, raw...)
+				continue
+			}
+		}
+
+		b.Write(pending)
+		pending = nil
+		b.Write(raw)
+	}
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// recordingReader keeps bytes read, so that the source text of each token can be taken.
+// It is an io.ByteReader, so that xml.Decoder reads no more than it needs.
+type recordingReader struct {
+	r      *bufio.Reader
+	buf    []byte
+	offset int64 // of buf[0]
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+func (r *recordingReader) ReadByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, c)
+	}
+	return c, err
+}
+
+// take returns bytes up to offset, and forgets them.
+func (r *recordingReader) take(offset int64) []byte {
+	n := int(offset - r.offset)
+	taken := bytes.Clone(r.buf[:n])
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	r.offset = offset
+	return taken
+}
+
+// StreamDelete is Delete reading and writing element by element. xpath must be streamable (see parseStreamPath).
+func StreamDelete(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string) error {
+	sp, err := parseStreamPath(xpath)
+	if err != nil {
+		return err
+	}
+
+	return streamEdit(input, output, sp, ns, func(doc *xmlquery.Node) error {
+		if sp.tail == "" {
+			w := rootElement(doc)
+			for c := w.FirstChild; c != nil; c = w.FirstChild {
+				xmlquery.RemoveFromTree(c)
+			}
+			return nil
+		}
+
+		deleteNodes(doc, errOutput, "/_/*/"+sp.tail, ns)
+		return nil
+	})
+}
+
+// StreamReplace is Replace reading and writing element by element. xpath must be streamable (see parseStreamPath).
+func StreamReplace(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, value, abbrev, target string) error {
+	sp, err := parseStreamPath(xpath)
+	if err != nil {
+		return err
+	}
+
+	rel := "/_/*"
+	if sp.tail != "" {
+		rel += "/" + sp.tail
+	}
+
+	return streamEdit(input, output, sp, ns, func(doc *xmlquery.Node) error {
+		replaceNodes(doc, errOutput, rel, ns, value, abbrev, target)
+		return nil
+	})
+}
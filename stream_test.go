@@ -0,0 +1,161 @@
+package main_test
+
+import (
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+const streamsrc = `<?xml version="1.0"?>
+<!-- export -->
+<export xmlns:x="urn:x">
+  <record id="1"><name>a</name><x:tag>t1</x:tag></record>
+  <record id="2" status="old"><name>b</name></record>
+  <group>
+    <record id="3"><name>c &amp; d</name></record>
+  </group>
+</export>
+`
+
+func TestStreamGet(t *testing.T) {
+	data := []struct {
+		xpath    string
+		multiple bool
+		format   string
+		err      string
+		out      string
+	}{
+		{xpath: `/export/record`, out: "record\n"},
+		{xpath: `/export/record/name`, multiple: true, format: "text", out: "a,b\n"},
+		{xpath: `//record/@id`, multiple: true, format: "text", out: "1,2,3\n"},
+		{xpath: `//record[@status='old']`, format: "outer-xml", out: `<record id="2" status="old"><name>b</name></record>` + "\n"},
+		{xpath: `//record[name='c & d']/@id`, format: "text", out: "3\n"},
+		{xpath: `/export/record/x:tag`, format: "text", out: "t1\n"},
+		{xpath: `//record[last()]`, err: `stream: "//record[last()]" cannot be streamed (the predicate [last()] requires look-ahead or elements outside the matched one)`},
+		{xpath: `//record[2]`, err: `stream: "//record[2]" cannot be streamed (the predicate [2] requires look-ahead or elements outside the matched one)`},
+		{xpath: `//record[@id!='2'][name!='a']/@id`, multiple: true, format: "text", out: "3\n"},
+		{xpath: `/export/record[@id!='1'][2]`, err: `stream: "/export/record[@id!='1'][2]" cannot be streamed (the predicate [2] requires look-ahead or elements outside the matched one)`},
+		{xpath: `//record[@id]x`, err: `stream: "//record[@id]x": unexpected "x" after a predicate`},
+		{xpath: `//record[@id]/name[following-sibling::x:tag]`, err: `stream: "//record[@id]/name[following-sibling::x:tag]" cannot be streamed (name[following-sibling::x:tag] requires look-ahead or elements outside the matched one)`},
+		{xpath: `//name/..`, err: `stream: "//name/.." cannot be streamed (.. requires look-ahead or elements outside the matched one)`},
+		{xpath: `count(//record)`, err: `stream: "count(//record)" must be an absolute path`},
+		{xpath: `//record`, format: "json", err: `stream: format "json" is not available`},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		in, out, _ := prepare(streamsrc)
+		err := main.StreamGet(main.NewFakeCloseReader(in), out, d.xpath, nil, d.multiple, ",", d.format, false, main.OutputConfig{EmptyElement: true})
+		if d.err != "" {
+			gotwant.Test(t, err.Error(), d.err, gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestStreamDelete(t *testing.T) {
+	data := []struct {
+		xpath string
+		err   string
+		out   string
+	}{
+		{
+			xpath: `//record[@status='old']`,
+			out: `<?xml version="1.0"?>
+<!-- export -->
+<export xmlns:x="urn:x">
+  <record id="1"><name>a</name><x:tag>t1</x:tag></record>
+  <group>
+    <record id="3"><name>c &amp; d</name></record>
+  </group>
+</export>
+`,
+		},
+		{
+			xpath: `/export/record/@id`,
+			out: `<?xml version="1.0"?>
+<!-- export -->
+<export xmlns:x="urn:x">
+  <record><name>a</name><x:tag>t1</x:tag></record>
+  <record status="old"><name>b</name></record>
+  <group>
+    <record id="3"><name>c &amp; d</name></record>
+  </group>
+</export>
+`,
+		},
+		{
+			xpath: `//record/following-sibling::group`,
+			err:   `stream: "//record/following-sibling::group" cannot be streamed (following-sibling::group requires look-ahead or elements outside the matched one)`,
+		},
+	}
+
+	for i, d := range data {
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		in, out, errout := prepare(streamsrc)
+		err := main.StreamDelete(main.NewFakeCloseReader(in), out, errout, d.xpath, nil)
+		if d.err != "" {
+			gotwant.Test(t, err.Error(), d.err, gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), "", gotwant.Desc(seq))
+	}
+}
+
+func TestStreamReplace(t *testing.T) {
+	in, out, errout := prepare(streamsrc)
+	err := main.StreamReplace(main.NewFakeCloseReader(in), out, errout, `//record[@id>1]/name`, nil, "z", "", main.TargetText)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?>
+<!-- export -->
+<export xmlns:x="urn:x">
+  <record id="1"><name>a</name><x:tag>t1</x:tag></record>
+  <record id="2" status="old"><name>z</name></record>
+  <group>
+    <record id="3"><name>z</name></record>
+  </group>
+</export>
+`)
+
+	in, out, errout = prepare(streamsrc)
+	err = main.StreamReplace(main.NewFakeCloseReader(in), out, errout, `/export/record[@id='1']`, nil, `<item/>`, "", main.TargetOuter)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?>
+<!-- export -->
+<export xmlns:x="urn:x">
+  <item/>
+  <record id="2" status="old"><name>b</name></record>
+  <group>
+    <record id="3"><name>c &amp; d</name></record>
+  </group>
+</export>
+`)
+}
+
+func TestStreamPrefixedAttr(t *testing.T) {
+	const src = `<?xml version="1.0"?>
+<root xmlns:x="urn:x"><a id="1" x:id="2"/></root>
+`
+
+	in, out, errout := prepare(src)
+	err := main.StreamDelete(main.NewFakeCloseReader(in), out, errout, `/root/a/@x:id`, nil)
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?>
+<root xmlns:x="urn:x"><a id="1"/></root>
+`)
+
+	in, out, errout = prepare(src)
+	err = main.StreamReplace(main.NewFakeCloseReader(in), out, errout, `/root/a/@x:id`, nil, "9", "", "")
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, readAll(out), `<?xml version="1.0"?>
+<root xmlns:x="urn:x"><a id="1" x:id="9"/></root>
+`)
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type validateCmd struct {
+	_ struct{} `help:"eksemel validate --xsd schema.xsd hoge.xml"`
+
+	XSD string `cli:"xsd=FILE" help:"XML Schema"`
+	DTD string `cli:"dtd=FILE" help:"DTD (default: DOCTYPE of the document)"`
+}
+
+func (c validateCmd) Before() error {
+	if c.XSD != "" && c.DTD != "" {
+		return errors.New("--xsd and --dtd are exclusive")
+	}
+	return nil
+}
+
+// Validator is a schema to validate documents against.
+type Validator interface {
+	Validate(doc *xmlquery.Node) []Violation
+}
+
+// LoadValidator loads a DTD if filename ends with .dtd, or an XML Schema.
+func LoadValidator(filename string) (Validator, error) {
+	if strings.EqualFold(filepath.Ext(filename), ".dtd") {
+		return LoadDTD(filename)
+	}
+	return LoadSchema(filename)
+}
+
+// DoctypeValidator validates a document against the DTD in its DOCTYPE.
+type DoctypeValidator struct {
+	// Dir is the directory to resolve the external DTD.
+	Dir string
+}
+
+func (dv DoctypeValidator) Validate(doc *xmlquery.Node) []Violation {
+	d, err := documentDTD(doc, dv.Dir)
+	if err != nil {
+		return []Violation{{Path: "/", Message: err.Error()}}
+	}
+	if d == nil {
+		return []Violation{{Path: "/", Message: "no DOCTYPE"}}
+	}
+	return d.Validate(doc)
+}
+
+// ErrInvalid is returned by Validate if the document has violations.
+var ErrInvalid = errors.New("not valid against the schema")
+
+// Violation is a node not conforming to a schema.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// Validate writes violations of input against schema, a line for each.
+// ErrInvalid is returned after writing if any.
+func Validate(input io.ReadCloser, output io.Writer, schema Validator) error {
+	doc, err := parseInput(input, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+
+	violations := schema.Validate(doc)
+	for _, v := range violations {
+		fmt.Fprintln(output, v)
+	}
+
+	if len(violations) > 0 {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Validate returns violations of doc.
+func (s *Schema) Validate(doc *xmlquery.Node) []Violation {
+	v := &validator{schema: s}
+
+	root := rootElement(doc)
+	if root == nil {
+		v.report(doc, "no root element")
+		return v.violations
+	}
+
+	d := s.elements[root.Data]
+	if d == nil {
+		v.report(root, fmt.Sprintf("element <%s> is not declared", root.Data))
+		return v.violations
+	}
+	v.element(root, d)
+
+	return v.violations
+}
+
+type validator struct {
+	schema     *Schema
+	violations []Violation
+
+	// children of the element in validation, matched to declarations
+	assigned map[*xmlquery.Node]*elementDecl
+
+	// element names expected at missingAt, where a particle failed
+	missing   []string
+	missingAt int
+}
+
+func (v *validator) report(n *xmlquery.Node, msg string) {
+	v.violations = append(v.violations, Violation{Path: nodePath(n), Message: msg})
+}
+
+func (v *validator) element(n *xmlquery.Node, d *elementDecl) {
+	t := d.typ
+	if t == nil || t.anyType {
+		return
+	}
+
+	v.attributes(n, t)
+
+	var children []*xmlquery.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode {
+			children = append(children, c)
+		}
+	}
+
+	if st := t.simple; st != nil || t.text != nil {
+		if st == nil {
+			st = t.text
+		}
+		if len(children) > 0 {
+			v.report(children[0], "element content is not allowed")
+			return
+		}
+		if err := st.validate(n.InnerText()); err != nil {
+			v.report(n, err.Error())
+		}
+		return
+	}
+
+	if !t.mixed {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if (c.Type == xmlquery.TextNode || c.Type == xmlquery.CharDataNode) && !isWhitespaceText(c) {
+				v.report(c, "text is not allowed")
+				break
+			}
+		}
+	}
+
+	if t.content == nil {
+		if len(children) > 0 {
+			v.report(children[0], "element content is not allowed")
+		}
+		return
+	}
+
+	assigned := make(map[*xmlquery.Node]*elementDecl)
+	v.assigned = assigned
+	v.missing = nil
+	v.missingAt = 0
+
+	i, ok := v.match(t.content, children, 0)
+	if !ok || i < len(children) {
+		pos := i
+		if len(v.missing) > 0 && v.missingAt > pos {
+			pos = v.missingAt
+		}
+		expected := ""
+		if len(v.missing) > 0 && v.missingAt == pos {
+			expected = "<" + strings.Join(v.missing, "> or <") + ">"
+		}
+
+		switch {
+		case pos < len(children) && expected != "":
+			v.report(children[pos], fmt.Sprintf("unexpected element <%s>, expected %s", children[pos].Data, expected))
+		case pos < len(children):
+			v.report(children[pos], fmt.Sprintf("unexpected element <%s>", children[pos].Data))
+		case expected != "":
+			v.report(n, "missing element "+expected)
+		default:
+			v.report(n, "incomplete content")
+		}
+		children = children[:min(i, len(children))]
+	}
+
+	for _, c := range children {
+		if d := assigned[c]; d != nil {
+			v.element(c, d)
+		}
+	}
+}
+
+func (v *validator) attributes(n *xmlquery.Node, t *typeDef) {
+	decls := make(map[string]*attrDecl)
+	for _, a := range t.attrs {
+		decls[a.name] = a
+	}
+
+	present := make(map[string]bool)
+	for _, attr := range n.Attr {
+		switch {
+		case attr.Name.Space == "xmlns", attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			continue
+		case attr.Name.Space == "xml", attr.Name.Space == "xsi":
+			continue
+		}
+
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		path := nodePath(n) + "/@" + name
+
+		a := decls[attr.Name.Local]
+		if a == nil {
+			if !t.anyAttr {
+				v.violations = append(v.violations, Violation{Path: path, Message: "attribute is not declared"})
+			}
+			continue
+		}
+		present[a.name] = true
+
+		if a.typ != nil {
+			if err := a.typ.validate(attr.Value); err != nil {
+				v.violations = append(v.violations, Violation{Path: path, Message: err.Error()})
+				continue
+			}
+		}
+		if a.fixed != nil && attr.Value != *a.fixed {
+			v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf("%q must be %q", attr.Value, *a.fixed)})
+		}
+	}
+
+	for _, a := range t.attrs {
+		if a.required && !present[a.name] {
+			v.report(n, fmt.Sprintf("missing attribute @%s", a.name))
+		}
+	}
+}
+
+func (v *validator) accepts(p *particle, n *xmlquery.Node) bool {
+	switch p.kind {
+	case "element":
+		return n.Data == p.elem.name
+	case "any":
+		return true
+	}
+	return false
+}
+
+func (v *validator) expect(name string, i int) {
+	switch {
+	case i > v.missingAt || len(v.missing) == 0:
+		v.missing = []string{name}
+		v.missingAt = i
+	case i == v.missingAt:
+		for _, m := range v.missing {
+			if m == name {
+				return
+			}
+		}
+		v.missing = append(v.missing, name)
+	}
+}
+
+// match matches p to children from i, and returns the next index.
+// Content models are deterministic in XSD (Unique Particle Attribution), so greedy matching suffices.
+func (v *validator) match(p *particle, children []*xmlquery.Node, i int) (int, bool) {
+	switch p.kind {
+	case "element", "any":
+		count := 0
+		for i < len(children) && (p.max < 0 || count < p.max) && v.accepts(p, children[i]) {
+			if p.kind == "element" {
+				v.assigned[children[i]] = p.elem
+			} else {
+				// lax
+				v.assigned[children[i]] = v.schema.elements[children[i].Data]
+			}
+			i++
+			count++
+		}
+		if count < p.min {
+			if p.kind == "element" {
+				v.expect(p.elem.name, i)
+			} else {
+				v.expect("*", i)
+			}
+			return i, false
+		}
+		return i, true
+
+	case "all":
+		used := make([]bool, len(p.items))
+		matched := false
+		for i < len(children) {
+			found := false
+			for k, item := range p.items {
+				if !used[k] && v.accepts(item, children[i]) {
+					i, _ = v.match(item, children, i)
+					used[k] = true
+					found = true
+					matched = true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+		}
+		for k, item := range p.items {
+			if !used[k] && item.min > 0 && (matched || p.min > 0) {
+				v.expect(item.elem.name, i)
+				return i, false
+			}
+		}
+		return i, true
+	}
+
+	count := 0
+	for p.max < 0 || count < p.max {
+		j, ok := v.matchOnce(p, children, i)
+		if !ok {
+			break
+		}
+		count++
+		if j == i {
+			count = max(count, p.min)
+			break
+		}
+		i = j
+	}
+	return i, count >= p.min
+}
+
+func (v *validator) matchOnce(p *particle, children []*xmlquery.Node, i int) (int, bool) {
+	if p.kind == "sequence" {
+		j := i
+		for _, item := range p.items {
+			var ok bool
+			j, ok = v.match(item, children, j)
+			if !ok {
+				return i, false
+			}
+		}
+		return j, true
+	}
+
+	// choice
+	empty := false
+	for _, item := range p.items {
+		j, ok := v.match(item, children, i)
+		if ok && j > i {
+			return j, true
+		}
+		empty = empty || ok
+	}
+	return i, empty
+}
+
+func (c validateCmd) Run(args []string) error {
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	var schema Validator
+	switch {
+	case c.XSD != "":
+		schema, err = LoadSchema(c.XSD)
+	case c.DTD != "":
+		schema, err = LoadDTD(c.DTD)
+	default:
+		schema = DoctypeValidator{Dir: filepath.Dir(filename)}
+	}
+	if err != nil {
+		return err
+	}
+
+	err = Validate(input, os.Stdout, schema)
+	if errors.Is(err, ErrInvalid) {
+		os.Exit(1)
+	}
+	return err
+}
@@ -0,0 +1,274 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+const testXSD = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="config">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="name" type="xs:token"/>
+        <xs:element name="port" type="portType" minOccurs="0"/>
+        <xs:choice minOccurs="0" maxOccurs="unbounded">
+          <xs:element ref="option"/>
+          <xs:element name="flag" type="xs:boolean"/>
+        </xs:choice>
+        <xs:element name="tags" minOccurs="0">
+          <xs:simpleType>
+            <xs:list itemType="xs:NCName"/>
+          </xs:simpleType>
+        </xs:element>
+      </xs:sequence>
+      <xs:attribute name="version" type="xs:decimal" use="required"/>
+      <xs:attribute name="mode" type="modeType"/>
+    </xs:complexType>
+  </xs:element>
+
+  <xs:element name="option">
+    <xs:complexType>
+      <xs:simpleContent>
+        <xs:extension base="xs:string">
+          <xs:attribute name="name" use="required">
+            <xs:simpleType>
+              <xs:restriction base="xs:string">
+                <xs:pattern value="[a-z][a-z0-9\-]*"/>
+                <xs:maxLength value="8"/>
+              </xs:restriction>
+            </xs:simpleType>
+          </xs:attribute>
+        </xs:extension>
+      </xs:simpleContent>
+    </xs:complexType>
+  </xs:element>
+
+  <xs:simpleType name="portType">
+    <xs:restriction base="xs:int">
+      <xs:minInclusive value="1"/>
+      <xs:maxInclusive value="65535"/>
+    </xs:restriction>
+  </xs:simpleType>
+
+  <xs:simpleType name="modeType">
+    <xs:restriction base="xs:string">
+      <xs:enumeration value="dev"/>
+      <xs:enumeration value="prod"/>
+    </xs:restriction>
+  </xs:simpleType>
+</xs:schema>
+`
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	xsd := filepath.Join(dir, "config.xsd")
+	os.WriteFile(xsd, []byte(testXSD), 0644)
+
+	schema, err := main.LoadSchema(xsd)
+	gotwant.TestError(t, err, nil)
+
+	data := []struct {
+		input string
+		err   error
+		out   string
+	}{
+		{
+			input: `<config version="1.0" mode="dev">
+  <name> app </name>
+  <port>8080</port>
+  <option name="verbose">yes</option>
+  <flag>true</flag>
+  <option name="x1"/>
+  <tags>a b c</tags>
+</config>`,
+			out: ``,
+		},
+		{
+			input: `<config version="1.0"><name>app</name></config>`,
+			out:   ``,
+		},
+		{
+			input: `<config mode="test" extra="1"><port>0</port></config>`,
+			err:   main.ErrInvalid,
+			out: `/config/@mode: "test" is not in the enumeration ["dev" "prod"]` + "\n" +
+				`/config/@extra: attribute is not declared` + "\n" +
+				`/config: missing attribute @version` + "\n" +
+				`/config/port: unexpected element <port>, expected <name>` + "\n",
+		},
+		{
+			input: `<config version="x"><name>app</name><port>70000</port><flag>yes</flag><option name="Bad">v</option><option/><unknown/></config>`,
+			err:   main.ErrInvalid,
+			out: `/config/@version: "x" is not a valid decimal` + "\n" +
+				`/config/unknown: unexpected element <unknown>, expected <option> or <flag>` + "\n" +
+				`/config/port: "70000" must be <= 65535` + "\n" +
+				`/config/flag: "yes" is not a valid boolean` + "\n" +
+				`/config/option[1]/@name: "Bad" does not match the pattern [a-z][a-z0-9\-]*` + "\n" +
+				`/config/option[2]: missing attribute @name` + "\n",
+		},
+		{
+			input: `<config version="1"><name>app<x/></name><tags>a 1</tags></config>`,
+			err:   main.ErrInvalid,
+			out: `/config/name/x: element content is not allowed` + "\n" +
+				`/config/tags: "1" is not a valid NCName` + "\n",
+		},
+		{
+			input: `<other/>`,
+			err:   main.ErrInvalid,
+			out:   `/other: element <other> is not declared` + "\n",
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.Validate(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, schema)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "types.xsd"), []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:complexType name="listType">
+    <xs:sequence>
+      <xs:element name="item" type="itemType" maxOccurs="3"/>
+    </xs:sequence>
+  </xs:complexType>
+  <xs:complexType name="itemType" mixed="true">
+    <xs:sequence>
+      <xs:element name="list" type="listType" minOccurs="0"/>
+    </xs:sequence>
+  </xs:complexType>
+</xs:schema>`), 0644)
+	os.WriteFile(filepath.Join(dir, "main.xsd"), []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:include schemaLocation="types.xsd"/>
+  <xs:element name="list" type="listType"/>
+</xs:schema>`), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.xsd"), []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="list" type="noSuchType"/>
+</xs:schema>`), 0644)
+
+	schema, err := main.LoadSchema(filepath.Join(dir, "main.xsd"))
+	gotwant.TestError(t, err, nil)
+
+	out := &bytes.Buffer{}
+	err = main.Validate(main.NewFakeCloseReader(bytes.NewBufferString(
+		`<list><item>a<list><item/><item/><item/><item/></list></item></list>`)), out, schema)
+	gotwant.TestError(t, err, main.ErrInvalid)
+	gotwant.Test(t, out.String(), `/list/item/list/item[4]: unexpected element <item>`+"\n")
+
+	_, err = main.LoadSchema(filepath.Join(dir, "broken.xsd"))
+	gotwant.Test(t, err.Error(), `schema: type "noSuchType" is not defined`)
+}
+
+func TestValidateFacets(t *testing.T) {
+	dir := t.TempDir()
+	xsd := filepath.Join(dir, "facets.xsd")
+	os.WriteFile(xsd, []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:attribute name="long" type="xs:long"/>
+      <xs:attribute name="ulong" type="xs:unsignedLong"/>
+      <xs:attribute name="big">
+        <xs:simpleType>
+          <xs:restriction base="xs:integer">
+            <xs:maxInclusive value="9223372036854775807"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:attribute>
+      <xs:attribute name="name">
+        <xs:simpleType>
+          <xs:restriction base="xs:string">
+            <xs:pattern value="\i[\c-]*"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:attribute>
+      <xs:attribute name="path">
+        <xs:simpleType>
+          <xs:restriction base="xs:string">
+            <xs:pattern value="[a-z]+\\i"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:attribute>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`), 0644)
+
+	schema, err := main.LoadSchema(xsd)
+	gotwant.TestError(t, err, nil)
+
+	data := []struct {
+		input string
+		err   error
+		out   string
+	}{
+		{
+			input: `<r long="9223372036854775807" ulong="18446744073709551615" big="9223372036854775807" name="a-b" path="ab\i"/>`,
+			out:   ``,
+		},
+		{
+			input: `<r long="9223372036854775808" ulong="18446744073709551616" big="9223372036854775808" name="-a" path="abi"/>`,
+			err:   main.ErrInvalid,
+			out: `/r/@long: "9223372036854775808" is out of range of long` + "\n" +
+				`/r/@ulong: "18446744073709551616" is out of range of unsignedLong` + "\n" +
+				`/r/@big: "9223372036854775808" must be <= 9223372036854775807` + "\n" +
+				`/r/@name: "-a" does not match the pattern \i[\c-]*` + "\n" +
+				`/r/@path: "abi" does not match the pattern [a-z]+\\i` + "\n",
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.Validate(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, schema)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+
+	os.WriteFile(xsd, []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="t">
+    <xs:restriction base="xs:string">
+      <xs:pattern value="[\I]"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:element name="r" type="t"/>
+</xs:schema>`), 0644)
+	_, err = main.LoadSchema(xsd)
+	gotwant.Test(t, err.Error(), `schema: pattern "[\\I]": \I in a character class is not supported`)
+
+	os.WriteFile(xsd, []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="t">
+    <xs:restriction base="xs:string">
+      <xs:pattern value="[a-z-[aeiou]]+"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:element name="r" type="t"/>
+</xs:schema>`), 0644)
+	_, err = main.LoadSchema(xsd)
+	gotwant.Test(t, err.Error(), `schema: pattern "[a-z-[aeiou]]+": character class subtraction is not supported`)
+
+	os.WriteFile(xsd, []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:all>
+        <xs:element name="a"/>
+        <xs:sequence/>
+      </xs:all>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`), 0644)
+	_, err = main.LoadSchema(xsd)
+	gotwant.Test(t, err.Error(), `schema: all: unexpected sequence (only elements are allowed)`)
+}
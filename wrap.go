@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/shu-go/ennet"
+)
+
+type wrapCmd struct {
+	_ struct{} `help:"eksemel wrap --xpath //option --ennet options hoge.xml"`
+
+	XPath string `cli:"xpath" help:"nodes to wrap" required:"true"`
+	Name  string `cli:"name" help:"name of the new parent"`
+	Ennet string `cli:"ennet" help:"emmet-like abbreviation of the new parent (nodes go into the last innermost element)"`
+
+	Group bool `cli:"group" help:"wrap nodes of the same parent together"`
+
+	common
+}
+
+type unwrapCmd struct {
+	_ struct{} `help:"eksemel unwrap --xpath //options hoge.xml"`
+
+	XPath string `cli:"xpath" help:"elements to remove keeping their children" required:"true"`
+
+	common
+}
+
+func (c wrapCmd) Before() error {
+	if c.Name == "" && c.Ennet == "" {
+		return errors.New("either --name or --ennet is required")
+	}
+
+	return nil
+}
+
+// Wrap puts each node matched by xpath in a new parent, named name or expanded from abbrev.
+// With group, nodes of the same parent are put in one new parent at the place of the first one.
+func Wrap(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, name, abbrev string, group bool, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	wrapNodes(doc, errOutput, xpath, ns, name, abbrev, group)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func wrapNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string, name, abbrev string, group bool) {
+	nodes, err := queryAll(doc, xpath, ns)
+	if err != nil {
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
+		return
+	}
+
+	var wrapper *xmlquery.Node
+	if abbrev != "" {
+		s, err := ennet.Expand(abbrev)
+		if err != nil {
+			fmt.Fprintf(errOutput, "ennet: %v\n", err)
+			return
+		}
+		fragment, err := parseFragment(s)
+		if err != nil {
+			fmt.Fprintf(errOutput, "ennet: %v\n", err)
+			return
+		}
+		for _, f := range fragment {
+			if f.Type != xmlquery.ElementNode {
+				continue
+			}
+			if wrapper != nil {
+				fmt.Fprintf(errOutput, "ennet: %v must be an element\n", abbrev)
+				return
+			}
+			wrapper = f
+		}
+		if wrapper == nil {
+			fmt.Fprintf(errOutput, "ennet: %v must be an element\n", abbrev)
+			return
+		}
+	} else {
+		wrapper = &xmlquery.Node{
+			Type: xmlquery.ElementNode,
+			Data: name,
+		}
+	}
+
+	var groups [][]*xmlquery.Node
+	for _, n := range nodes {
+		if n.Type == xmlquery.AttributeNode || n.Parent == nil {
+			fmt.Fprintf(errOutput, "xpath: %v cannot be wrapped\n", n.Data)
+			continue
+		}
+
+		if group {
+			found := false
+			for i := range groups {
+				if groups[i][0].Parent == n.Parent {
+					groups[i] = append(groups[i], n)
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+		}
+		groups = append(groups, []*xmlquery.Node{n})
+	}
+
+	for _, g := range groups {
+		w := CloneNode(wrapper)
+		InsertNode(g[0], w, PositionBefore)
+
+		inner := w
+		for e := lastElement(inner); e != nil; e = lastElement(inner) {
+			inner = e
+		}
+
+		for _, n := range g {
+			xmlquery.RemoveFromTree(n)
+			InsertNode(inner, n, PositionLastChild)
+		}
+	}
+}
+
+// lastElement returns the last child element of n.
+func lastElement(n *xmlquery.Node) *xmlquery.Node {
+	for c := n.LastChild; c != nil; c = c.PrevSibling {
+		if c.Type == xmlquery.ElementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+// Unwrap removes elements matched by xpath, keeping their children in their places.
+func Unwrap(input io.ReadCloser, output, errOutput io.Writer, xpath string, ns map[string]string, config OutputConfig) error {
+	doc, err := parseInput(input, &config)
+	if err != nil {
+		return err
+	}
+
+	unwrapNodes(doc, errOutput, xpath, ns)
+
+	OutputXML(output, doc, config)
+
+	return nil
+}
+
+func unwrapNodes(doc *xmlquery.Node, errOutput io.Writer, xpath string, ns map[string]string) {
+	nodes, err := queryAll(doc, xpath, ns)
+	if err != nil {
+		fmt.Fprintf(errOutput, "xpath: %v\n", err)
+		return
+	}
+
+	for _, n := range nodes {
+		if n.Type != xmlquery.ElementNode {
+			fmt.Fprintf(errOutput, "xpath: %v is not an element node\n", n.Data)
+			continue
+		}
+		if n.Parent != nil && n.Parent.Type == xmlquery.DocumentNode && !hasSingleRoot(n) {
+			fmt.Fprintf(errOutput, "xpath: the document element %v cannot be unwrapped (it must have exactly one element child and no text)\n", n.Data)
+			continue
+		}
+
+		for c := n.FirstChild; c != nil; c = n.FirstChild {
+			xmlquery.RemoveFromTree(c)
+			InsertNode(n, c, PositionBefore)
+		}
+		xmlquery.RemoveFromTree(n)
+	}
+}
+
+// hasSingleRoot reports whether the children of n can be the top level of a document:
+// exactly one element, and no text other than whitespace.
+func hasSingleRoot(n *xmlquery.Node) bool {
+	elements := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case xmlquery.ElementNode:
+			elements++
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if strings.TrimSpace(c.Data) != "" {
+				return false
+			}
+		}
+	}
+	return elements == 1
+}
+
+func (c wrapCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	wrapNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns), c.Name, c.Ennet, c.Group)
+	return nil
+}
+
+func (c wrapCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Wrap(input, w, os.Stderr, c.XPath, ns, c.Name, c.Ennet, c.Group, c.outputConfig(filename))
+	})
+}
+
+func (c unwrapCmd) apply(doc *xmlquery.Node, ns map[string]string, output, errOutput io.Writer) error {
+	cns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	unwrapNodes(doc, errOutput, c.XPath, mergeNamespaces(ns, cns))
+	return nil
+}
+
+func (c unwrapCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return Unwrap(input, w, os.Stderr, c.XPath, ns, c.outputConfig(filename))
+	})
+}
@@ -0,0 +1,155 @@
+package main_test
+
+import (
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+type wraptestdata struct {
+	input string
+	xpath string
+	name  string
+	ennet string
+	group bool
+
+	err         error
+	out, errout string
+}
+
+func testwrap(t *testing.T, data []wraptestdata) {
+	t.Helper()
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+		err := main.Wrap(
+			main.NewFakeCloseReader(in),
+			out,
+			errout,
+			d.xpath,
+			nil,
+			d.name,
+			d.ennet,
+			d.group,
+			main.OutputConfig{EmptyElement: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), d.errout, gotwant.Desc(seq))
+	}
+}
+
+func TestWrap(t *testing.T) {
+	testwrap(t, []wraptestdata{
+		{
+			// only child
+			input: xmlpi + `<root><a/></root>`,
+			xpath: `//a`,
+			name:  "w",
+			out:   xmlpi + `<root><w><a/></w></root>`,
+		},
+		{
+			// first, middle and last
+			input: xmlpi + `<root><a/><b/><a/><b/><a/></root>`,
+			xpath: `//a`,
+			name:  "w",
+			out:   xmlpi + `<root><w><a/></w><b/><w><a/></w><b/><w><a/></w></root>`,
+		},
+		{
+			input: xmlpi + `<root><a/><b/><a/></root><!--x-->`,
+			xpath: `//a`,
+			name:  "w",
+			group: true,
+			out:   xmlpi + `<root><w><a/><a/></w><b/></root><!--x-->`,
+		},
+		{
+			input: xmlpi + `<root>text<a/></root>`,
+			xpath: `/root/text()`,
+			ennet: `w[id=1]>x+y`,
+			out:   xmlpi + `<root><w id="1"><x/><y>text</y></w><a/></root>`,
+		},
+		{
+			input:  xmlpi + `<root><a/></root>`,
+			xpath:  `//a`,
+			ennet:  `x+y`,
+			out:    xmlpi + `<root><a/></root>`,
+			errout: "ennet: x+y must be an element\n",
+		},
+		{
+			input:  xmlpi + `<root><a id="1"/></root>`,
+			xpath:  `//@id`,
+			name:   "w",
+			out:    xmlpi + `<root><a id="1"/></root>`,
+			errout: "xpath: id cannot be wrapped\n",
+		},
+	})
+}
+
+type unwraptestdata struct {
+	input string
+	xpath string
+
+	err         error
+	out, errout string
+}
+
+func TestUnwrap(t *testing.T) {
+	data := []unwraptestdata{
+		{
+			input: xmlpi + `<root><w><a/>text<b/></w></root>`,
+			xpath: `//w`,
+			out:   xmlpi + `<root><a/>text<b/></root>`,
+		},
+		{
+			input: xmlpi + `<root><w><a/></w><b/><w/><b/><w><w>x</w></w></root>`,
+			xpath: `//w`,
+			out:   xmlpi + `<root><a/><b/><b/>x</root>`,
+		},
+		{
+			input:  xmlpi + `<root>text</root>`,
+			xpath:  `//text()`,
+			out:    xmlpi + `<root>text</root>`,
+			errout: "xpath: text is not an element node\n",
+		},
+		{
+			input: xmlpi + `<root> <!--c--><a><b/></a> </root>`,
+			xpath: `/root`,
+			out:   xmlpi + `<!--c--><a><b/></a>`,
+		},
+		{
+			input:  xmlpi + `<root><a/><b/></root>`,
+			xpath:  `/root`,
+			out:    xmlpi + `<root><a/><b/></root>`,
+			errout: "xpath: the document element root cannot be unwrapped (it must have exactly one element child and no text)\n",
+		},
+		{
+			input:  xmlpi + `<root>text<a/></root>`,
+			xpath:  `/root`,
+			out:    xmlpi + `<root>text<a/></root>`,
+			errout: "xpath: the document element root cannot be unwrapped (it must have exactly one element child and no text)\n",
+		},
+	}
+
+	for i, d := range data {
+		in, out, errout := prepare(d.input)
+		err := main.Unwrap(
+			main.NewFakeCloseReader(in),
+			out,
+			errout,
+			d.xpath,
+			nil,
+			main.OutputConfig{EmptyElement: true},
+		)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, d.err, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(out), d.out, gotwant.Desc(seq))
+		gotwant.Test(t, readAll(errout), d.errout, gotwant.Desc(seq))
+	}
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"html"
 	"io"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/antchfx/xmlquery"
 )
@@ -13,14 +13,110 @@ import (
 type OutputConfig struct {
 	EmptyElement bool
 	Indent       string
+
+	// Preserve writes untouched nodes as they were in the source.
+	// It requires the document parsed with the config (see parseInput).
+	Preserve bool
+	source   *sourceMap
+
+	// DTDDefaults adds default attribute values declared in DOCTYPE on parsing.
+	// The external DTD is read relative to InputDir (the directory of the input file), and skipped if it cannot be read.
+	DTDDefaults bool
+	InputDir    string
+
+	// C14N writes canonical XML, C14NInclusive or C14NExclusive. Other options are ignored.
+	C14N         string
+	C14NComments bool
+
+	// Encoding is the character encoding to write in, updating the XML declaration.
+	// If empty, the encoding of the input (see parseInput) or UTF-8 is used.
+	Encoding string
+	encoding *textEncoding
+
+	// BOM writes a BOM in UTF-8. A BOM of the input is kept without it.
+	BOM bool
+
+	// EOL is the line ending, EOLLF, EOLCRLF or EOLPreserve (as the first line of the input), including ones in texts and comments.
+	EOL string
+	eol string
+
+	// Escape is how to escape texts and attribute values, EscapeMinimal (default), EscapeASCII or EscapePreserve.
+	Escape string
+	// Quote is the quote of attribute values, QuoteDouble (default) or QuoteSingle.
+	Quote string
+
+	// PreserveSpace is XPaths to elements whose content is written as it is, like ones with xml:space="preserve".
+	// Namespaces binds prefixes in them, in addition to ones declared in the document.
+	PreserveSpace []string
+	Namespaces    map[string]string
+	preserved     map[*xmlquery.Node]bool
+
+	// AttrOrder is the order of attributes, AttrOrderPreserve (default) or AttrOrderAlphabetical.
+	AttrOrder string
+
+	// MaxWidth puts attributes of a start tag longer than it on separate lines, aligned with the first one.
+	// Tabs in Indent count as TabWidth columns (8 if 0).
+	MaxWidth int
+	TabWidth int
+
+	// KeepBlankLines keeps blank lines between children of the document element with Indent.
+	KeepBlankLines bool
 }
 
+// Quotes of OutputConfig.Quote
+const (
+	QuoteDouble = "double"
+	QuoteSingle = "single"
+)
+
+// Orders of OutputConfig.AttrOrder
+const (
+	AttrOrderPreserve     = "preserve"
+	AttrOrderAlphabetical = "alphabetical"
+)
+
 func OutputXML(out io.Writer, n *xmlquery.Node, config OutputConfig) {
-	b := bufio.NewWriter(out)
 	level := 0
 
+	if config.C14N != "" {
+		// always in UTF-8
+		b := bufio.NewWriter(out)
+		writeC14N(b, n, config)
+		b.Flush()
+		return
+	}
+
+	// a document in its encoding, and fragments (e.g. by get) in UTF-8
+	if n.Type == xmlquery.DocumentNode {
+		te := config.outputEncoding()
+		if config.Encoding != "" {
+			setDeclaredEncoding(n, te.name)
+		}
+		w, closeEncoder := encodeOutput(out, te)
+		defer closeEncoder()
+		out = newEOLWriter(w, config.lineEnding())
+	}
+	b := bufio.NewWriter(out)
+
+	if len(config.PreserveSpace) > 0 {
+		config.preserved = queryPreserveSpace(n, config.PreserveSpace, config.Namespaces)
+	}
+
+	if config.Preserve && config.source != nil {
+		config.source.write(b, n, config)
+		b.Flush()
+		return
+	}
+
 	if n.Type == xmlquery.DocumentNode {
 		curr := n.FirstChild
+		if curr != nil && curr.Type == xmlquery.DeclarationNode {
+			outputXML(b, curr, level, config)
+			curr = curr.NextSibling
+		}
+		for _, p := range prologNodes(n) {
+			outputXML(b, p, level, config)
+		}
 		for curr != nil {
 			outputXML(b, curr, level, config)
 			curr = curr.NextSibling
@@ -59,6 +155,91 @@ func AddNode(n, newnode *xmlquery.Node, sibling bool) {
 	newnode.Parent = parent
 }
 
+// InsertNode links newnode at pos (PositionLastChild, PositionFirstChild, PositionBefore or PositionAfter) of n.
+func InsertNode(n, newnode *xmlquery.Node, pos string) {
+	switch pos {
+	case PositionAfter:
+		AddNode(n, newnode, true)
+
+	case PositionBefore:
+		parent := n.Parent
+		newnode.Parent = parent
+		newnode.PrevSibling = n.PrevSibling
+		newnode.NextSibling = n
+		if n.PrevSibling != nil {
+			n.PrevSibling.NextSibling = newnode
+		} else {
+			parent.FirstChild = newnode
+		}
+		n.PrevSibling = newnode
+
+	case PositionFirstChild:
+		if n.FirstChild == nil {
+			AddNode(n, newnode, false)
+		} else {
+			InsertNode(n.FirstChild, newnode, PositionBefore)
+		}
+
+	default:
+		AddNode(n, newnode, false)
+	}
+}
+
+// prologNodes returns nodes before the root element of doc that are not its children.
+// xmlquery links them as siblings of the document node if there is no XML declaration.
+func prologNodes(doc *xmlquery.Node) []*xmlquery.Node {
+	var nodes []*xmlquery.Node
+	for n := doc.NextSibling; n != nil; n = n.NextSibling {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// CloneNode returns a deep copy of n, not linked to any tree.
+func CloneNode(n *xmlquery.Node) *xmlquery.Node {
+	nn := &xmlquery.Node{
+		Type:         n.Type,
+		Data:         n.Data,
+		Prefix:       n.Prefix,
+		NamespaceURI: n.NamespaceURI,
+		Attr:         append([]xmlquery.Attr(nil), n.Attr...),
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		xmlquery.AddChild(nn, CloneNode(c))
+	}
+	return nn
+}
+
+// parseFragment parses s as a sequence of nodes, not linked to any tree.
+// An XML declaration at the beginning is skipped.
+func parseFragment(s string) ([]*xmlquery.Node, error) {
+	if strings.HasPrefix(s, "<?xml ") {
+		if i := strings.Index(s, "?>"); i != -1 {
+			s = s[i+2:]
+		}
+	}
+
+	doc, err := xmlquery.Parse(strings.NewReader("<_>" + s + "</_>"))
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := doc.FirstChild
+	for wrapper != nil && wrapper.Type != xmlquery.ElementNode {
+		wrapper = wrapper.NextSibling
+	}
+	if wrapper == nil {
+		return nil, nil
+	}
+
+	var nodes []*xmlquery.Node
+	for c := wrapper.FirstChild; c != nil; c = wrapper.FirstChild {
+		xmlquery.RemoveFromTree(c)
+		nodes = append(nodes, c)
+	}
+	return nodes, nil
+}
+
 func outputXML(b *bufio.Writer, n *xmlquery.Node, level int, config OutputConfig) {
 	if n.Type == xmlquery.TextNode && strings.TrimSpace(n.Data) == "" {
 		return
@@ -73,16 +254,14 @@ func outputXML(b *bufio.Writer, n *xmlquery.Node, level int, config OutputConfig
 	case xmlquery.TextNode:
 		text := strings.TrimSpace(n.Data)
 		if text != "" {
-			b.WriteString(html.EscapeString(text))
+			b.WriteString(config.text(n, text))
 			if !isOnelineText(n) {
 				writeStylingNewLine(b, styling)
 			}
 		}
 		return
 	case xmlquery.CharDataNode:
-		b.WriteString("<![CDATA[")
-		b.WriteString(n.Data)
-		b.WriteString("]]>")
+		b.WriteString(cdata(n.Data))
 		writeStylingNewLine(b, styling)
 		return
 	case xmlquery.CommentNode:
@@ -97,24 +276,33 @@ func outputXML(b *bufio.Writer, n *xmlquery.Node, level int, config OutputConfig
 		return
 	case xmlquery.DeclarationNode:
 		b.WriteString("<?" + n.Data)
+		writePIAttrs(b, n.Attr)
+		b.WriteString("?>")
+		writeStylingNewLine(b, styling)
+		return
 	default:
 		b.WriteByte('<')
 		writeName(b, n.Prefix, n.Data)
 	}
 
-	for _, attr := range n.Attr {
-		b.WriteByte(' ')
-		writeName(b, attr.Name.Space, attr.Name.Local)
-		b.WriteByte('=')
-		b.WriteByte('"')
-		b.WriteString(html.EscapeString(attr.Value))
-		b.WriteByte('"')
-	}
-
-	if n.Type == xmlquery.DeclarationNode {
-		b.WriteString("?>")
-		writeStylingNewLine(b, styling)
-		return
+	attrs := config.attrs(n)
+	if styling && config.wrapsAttrs(n, attrs, level) {
+		// aligned with the first attribute
+		align := strings.Repeat(config.Indent, level) + strings.Repeat(" ", utf8.RuneCountInString("<"+n.Data+" ")+prefixLen(n))
+		for i, a := range attrs {
+			if i == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteByte('\n')
+				b.WriteString(align)
+			}
+			b.WriteString(a)
+		}
+	} else {
+		for _, a := range attrs {
+			b.WriteByte(' ')
+			b.WriteString(a)
+		}
 	}
 
 	if n.FirstChild == nil && config.EmptyElement {
@@ -125,6 +313,17 @@ func outputXML(b *bufio.Writer, n *xmlquery.Node, level int, config OutputConfig
 
 	b.WriteString(">")
 
+	if config.spacePreserved(n) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeVerbatim(b, child, config)
+		}
+		b.WriteString("</")
+		writeName(b, n.Prefix, n.Data)
+		b.WriteByte('>')
+		writeStylingNewLine(b, styling)
+		return
+	}
+
 	if styling {
 		newline := false
 		curr := n.FirstChild
@@ -141,6 +340,10 @@ func outputXML(b *bufio.Writer, n *xmlquery.Node, level int, config OutputConfig
 	}
 
 	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if styling && config.KeepBlankLines && level == 0 && isBlankLine(child) {
+			b.WriteByte('\n')
+			continue
+		}
 		outputXML(b, child, level+1, config)
 	}
 
@@ -156,6 +359,141 @@ func outputXML(b *bufio.Writer, n *xmlquery.Node, level int, config OutputConfig
 	writeStylingNewLine(b, styling)
 }
 
+// wrapsAttrs reports whether attributes of the element n at level are put on separate lines by config.MaxWidth.
+func (config OutputConfig) wrapsAttrs(n *xmlquery.Node, attrs []string, level int) bool {
+	if config.MaxWidth <= 0 || len(attrs) < 2 {
+		return false
+	}
+
+	tabWidth := config.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
+	width := level * (utf8.RuneCountInString(config.Indent) + strings.Count(config.Indent, "\t")*(tabWidth-1))
+
+	width += utf8.RuneCountInString("<"+n.Data) + prefixLen(n)
+	for _, a := range attrs {
+		width += 1 + utf8.RuneCountInString(a)
+	}
+	if n.FirstChild == nil && config.EmptyElement {
+		width += len("/>")
+	} else {
+		width += len(">")
+	}
+	return width > config.MaxWidth
+}
+
+// prefixLen returns the length of the prefix of the element n with the colon.
+func prefixLen(n *xmlquery.Node) int {
+	if n.Prefix == "" {
+		return 0
+	}
+	return utf8.RuneCountInString(n.Prefix) + 1
+}
+
+// isBlankLine reports whether n is whitespace with a blank line between two siblings.
+func isBlankLine(n *xmlquery.Node) bool {
+	return n.Type == xmlquery.TextNode &&
+		n.PrevSibling != nil && n.NextSibling != nil &&
+		strings.TrimSpace(n.Data) == "" &&
+		strings.Count(strings.ReplaceAll(n.Data, "\r", ""), "\n") >= 2
+}
+
+// spacePreserved reports whether the content of the element n is written as it is:
+// n has xml:space="preserve", is matched by OutputConfig.PreserveSpace, or has mixed content.
+// xml:space="default" on n takes precedence over OutputConfig.PreserveSpace.
+func (config OutputConfig) spacePreserved(n *xmlquery.Node) bool {
+	space := xmlSpace(n)
+	if space == "preserve" || space != "default" && config.preserved[n] {
+		return true
+	}
+
+	// a text with other nodes, or a space between inline elements (not indentation, which has a line break)
+	text, count := false, 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text = text || (c.Type == xmlquery.TextNode || c.Type == xmlquery.CharDataNode) && strings.TrimSpace(c.Data) != "" || isInlineSpace(c)
+		count++
+	}
+	return text && count > 1
+}
+
+// isInlineSpace reports whether n is whitespace without a line break between two element siblings.
+func isInlineSpace(n *xmlquery.Node) bool {
+	return n.Type == xmlquery.TextNode &&
+		n.PrevSibling != nil && n.PrevSibling.Type == xmlquery.ElementNode &&
+		n.NextSibling != nil && n.NextSibling.Type == xmlquery.ElementNode &&
+		strings.TrimSpace(n.Data) == "" && !strings.ContainsAny(n.Data, "\r\n")
+}
+
+// xmlSpace returns the value of xml:space on the element n.
+func xmlSpace(n *xmlquery.Node) string {
+	for _, attr := range n.Attr {
+		if attr.Name.Space == "xml" && attr.Name.Local == "space" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// queryPreserveSpace returns elements matched by exprs in the document of n.
+// Errors are reported by parseInput before.
+func queryPreserveSpace(n *xmlquery.Node, exprs []string, ns map[string]string) map[*xmlquery.Node]bool {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+
+	preserved := make(map[*xmlquery.Node]bool)
+	for _, expr := range exprs {
+		nodes, err := queryAll(n, expr, ns)
+		if err != nil {
+			continue
+		}
+		for _, e := range nodes {
+			preserved[e] = true
+		}
+	}
+	return preserved
+}
+
+// writeVerbatim writes n and its descendants without indentation nor trimming texts.
+// An element with xml:space="default" is written compact, as its whitespace is not significant.
+func writeVerbatim(b *bufio.Writer, n *xmlquery.Node, config OutputConfig) {
+	if n.Type == xmlquery.ElementNode && xmlSpace(n) == "default" {
+		compact := config
+		compact.Indent = ""
+		outputXML(b, n, 0, compact)
+		return
+	}
+
+	switch n.Type {
+	case xmlquery.TextNode:
+		b.WriteString(config.text(n, n.Data))
+	case xmlquery.CharDataNode:
+		b.WriteString(cdata(n.Data))
+	case xmlquery.CommentNode:
+		b.WriteString("<!--" + n.Data + "-->")
+	case xmlquery.DeclarationNode:
+		b.WriteString("<?" + n.Data)
+		writePIAttrs(b, n.Attr)
+		b.WriteString("?>")
+	case xmlquery.ElementNode:
+		b.WriteByte('<')
+		writeName(b, n.Prefix, n.Data)
+		writeAttrs(b, n, config)
+		if n.FirstChild == nil && config.EmptyElement {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteByte('>')
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeVerbatim(b, c, config)
+		}
+		b.WriteString("</")
+		writeName(b, n.Prefix, n.Data)
+		b.WriteByte('>')
+	}
+}
+
 func isOnelineText(n *xmlquery.Node) bool {
 	return n == nil ||
 		n.Type == xmlquery.TextNode &&
@@ -0,0 +1,686 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/antchfx/xmlquery"
+)
+
+const xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// Schema is a compiled XML Schema of a practical subset of XSD 1.0:
+// elements, complex and simple types, sequence, choice, all and groups, occurrences,
+// attributes, built-in datatypes, and facets (enumeration, pattern, length and range).
+//
+// Elements and attributes are matched by their local names; namespaces are not taken into account.
+type Schema struct {
+	elements map[string]*elementDecl
+
+	// named global definitions by "kind:name" (kind is element, attribute, type, group or attributeGroup)
+	defs map[string]*xmlquery.Node
+
+	decls   map[*xmlquery.Node]*elementDecl
+	types   map[*xmlquery.Node]*typeDef
+	simples map[*xmlquery.Node]*simpleType
+}
+
+type elementDecl struct {
+	name string
+	typ  *typeDef // nil for anyType
+}
+
+type typeDef struct {
+	simple *simpleType // a simple type
+
+	// a complex type
+	anyType bool
+	mixed   bool
+	content *particle   // nil for empty content
+	text    *simpleType // simple content
+	attrs   []*attrDecl
+	anyAttr bool
+}
+
+type attrDecl struct {
+	name     string
+	typ      *simpleType // nil for anySimpleType
+	required bool
+	fixed    *string
+}
+
+type particle struct {
+	kind     string // element, any, sequence, choice or all
+	min, max int    // max < 0 for unbounded
+
+	elem  *elementDecl
+	items []*particle
+}
+
+type simpleType struct {
+	builtin string
+	base    *simpleType
+	list    *simpleType
+	union   []*simpleType
+
+	enums    []string
+	patterns []xsdPattern
+
+	length, minLength, maxLength   int // < 0 for none
+	minInc, maxInc, minExc, maxExc string
+}
+
+// xsdPattern is a pattern facet translated into Go.
+type xsdPattern struct {
+	re  *regexp.Regexp
+	src string // as in the schema
+}
+
+// LoadSchema reads an XSD file. xs:include and xs:import are resolved relative to the file.
+func LoadSchema(filename string) (*Schema, error) {
+	var roots []*xmlquery.Node
+	loaded := make(map[string]bool)
+
+	var load func(filename string) error
+	load = func(filename string) error {
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			return err
+		}
+		if loaded[abs] {
+			return nil
+		}
+		loaded[abs] = true
+
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		doc, err := xmlquery.Parse(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		root := rootElement(doc)
+		if root == nil || root.Data != "schema" {
+			return fmt.Errorf("%s: not a schema", filename)
+		}
+		roots = append(roots, root)
+
+		for _, c := range xsdChildren(root) {
+			if c.Data != "include" && c.Data != "import" {
+				continue
+			}
+			if loc := c.SelectAttr("schemaLocation"); loc != "" {
+				if err := load(filepath.Join(filepath.Dir(filename), loc)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := load(filename); err != nil {
+		return nil, err
+	}
+
+	return compileSchema(roots)
+}
+
+func compileSchema(roots []*xmlquery.Node) (*Schema, error) {
+	s := &Schema{
+		elements: make(map[string]*elementDecl),
+		defs:     make(map[string]*xmlquery.Node),
+		decls:    make(map[*xmlquery.Node]*elementDecl),
+		types:    make(map[*xmlquery.Node]*typeDef),
+		simples:  make(map[*xmlquery.Node]*simpleType),
+	}
+
+	for _, root := range roots {
+		for _, c := range xsdChildren(root) {
+			kind := c.Data
+			switch kind {
+			case "complexType", "simpleType":
+				kind = "type"
+			case "element", "attribute", "group", "attributeGroup":
+			default:
+				continue
+			}
+			s.defs[kind+":"+c.SelectAttr("name")] = c
+		}
+	}
+
+	keys := make([]string, 0, len(s.defs))
+	for k := range s.defs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		n := s.defs[k]
+		var err error
+		switch {
+		case strings.HasPrefix(k, "element:"):
+			s.elements[n.SelectAttr("name")], err = s.elementDecl(n)
+		case strings.HasPrefix(k, "type:"):
+			_, err = s.typeDefOf(n)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schema: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// xsdChildren returns child elements of n except annotations.
+func xsdChildren(n *xmlquery.Node) []*xmlquery.Node {
+	var children []*xmlquery.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode && c.Data != "annotation" {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+func (s *Schema) lookup(kind, qname string) (*xmlquery.Node, error) {
+	_, local := splitQName(qname)
+	def := s.defs[kind+":"+local]
+	if def == nil {
+		return nil, fmt.Errorf("%s %q is not defined", kind, qname)
+	}
+	return def, nil
+}
+
+func (s *Schema) elementDecl(n *xmlquery.Node) (*elementDecl, error) {
+	if ref := n.SelectAttr("ref"); ref != "" {
+		def, err := s.lookup("element", ref)
+		if err != nil {
+			return nil, err
+		}
+		return s.elementDecl(def)
+	}
+
+	if d, found := s.decls[n]; found {
+		return d, nil
+	}
+	d := &elementDecl{name: n.SelectAttr("name")}
+	s.decls[n] = d
+
+	var err error
+	if t := n.SelectAttr("type"); t != "" {
+		d.typ, err = s.typeRef(n, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range xsdChildren(n) {
+		switch c.Data {
+		case "complexType":
+			d.typ, err = s.complexType(c)
+		case "simpleType":
+			var st *simpleType
+			st, err = s.simpleTypeOf(c)
+			d.typ = &typeDef{simple: st}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// typeRef resolves a type name in the context of n.
+func (s *Schema) typeRef(n *xmlquery.Node, qname string) (*typeDef, error) {
+	prefix, local := splitQName(qname)
+	if lookupNamespace(n, prefix) != xsdNamespace {
+		if def := s.defs["type:"+local]; def != nil {
+			return s.typeDefOf(def)
+		}
+	}
+
+	if local == "anyType" {
+		return &typeDef{anyType: true}, nil
+	}
+	if st := builtinType(local); st != nil {
+		return &typeDef{simple: st}, nil
+	}
+	return nil, fmt.Errorf("type %q is not defined", qname)
+}
+
+func (s *Schema) simpleRef(n *xmlquery.Node, qname string) (*simpleType, error) {
+	t, err := s.typeRef(n, qname)
+	if err != nil {
+		return nil, err
+	}
+	if t.simple == nil {
+		return nil, fmt.Errorf("type %q is not a simple type", qname)
+	}
+	return t.simple, nil
+}
+
+func (s *Schema) typeDefOf(def *xmlquery.Node) (*typeDef, error) {
+	if def.Data == "complexType" {
+		return s.complexType(def)
+	}
+
+	st, err := s.simpleTypeOf(def)
+	if err != nil {
+		return nil, err
+	}
+	return &typeDef{simple: st}, nil
+}
+
+func (s *Schema) complexType(n *xmlquery.Node) (*typeDef, error) {
+	if t, found := s.types[n]; found {
+		return t, nil
+	}
+	t := &typeDef{mixed: n.SelectAttr("mixed") == "true"}
+	s.types[n] = t
+
+	var err error
+	for _, c := range xsdChildren(n) {
+		switch c.Data {
+		case "sequence", "choice", "all", "group":
+			t.content, err = s.particle(c)
+
+		case "attribute", "attributeGroup", "anyAttribute":
+			err = s.addAttr(t, c)
+
+		case "simpleContent":
+			err = s.simpleContent(t, c)
+
+		case "complexContent":
+			if c.SelectAttr("mixed") == "true" {
+				t.mixed = true
+			}
+			err = s.complexContent(t, c)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (s *Schema) simpleContent(t *typeDef, n *xmlquery.Node) error {
+	for _, e := range xsdChildren(n) {
+		base, err := s.typeRef(e, e.SelectAttr("base"))
+		if err != nil {
+			return err
+		}
+		if base.simple != nil {
+			t.text = base.simple
+		} else {
+			t.text = base.text
+			t.attrs = append(t.attrs, base.attrs...)
+			t.anyAttr = base.anyAttr
+		}
+
+		if e.Data == "restriction" {
+			st := newSimpleType()
+			st.base = t.text
+			if err := s.facets(st, e); err != nil {
+				return err
+			}
+			t.text = st
+		}
+
+		for _, c := range xsdChildren(e) {
+			if err := s.addAttr(t, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) complexContent(t *typeDef, n *xmlquery.Node) error {
+	for _, e := range xsdChildren(n) {
+		base, err := s.typeRef(e, e.SelectAttr("base"))
+		if err != nil {
+			return err
+		}
+		t.attrs = append(t.attrs, base.attrs...)
+		t.anyAttr = base.anyAttr
+
+		var own *particle
+		for _, c := range xsdChildren(e) {
+			switch c.Data {
+			case "sequence", "choice", "all", "group":
+				own, err = s.particle(c)
+			default:
+				err = s.addAttr(t, c)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case e.Data == "restriction" || base.content == nil:
+			t.content = own
+		case own == nil:
+			t.content = base.content
+		default:
+			t.mixed = t.mixed || base.mixed
+			t.content = &particle{kind: "sequence", min: 1, max: 1, items: []*particle{base.content, own}}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) addAttr(t *typeDef, n *xmlquery.Node) error {
+	switch n.Data {
+	case "anyAttribute":
+		t.anyAttr = true
+
+	case "attributeGroup":
+		def, err := s.lookup("attributeGroup", n.SelectAttr("ref"))
+		if err != nil {
+			return err
+		}
+		for _, c := range xsdChildren(def) {
+			if err := s.addAttr(t, c); err != nil {
+				return err
+			}
+		}
+
+	case "attribute":
+		decl := n
+		if ref := n.SelectAttr("ref"); ref != "" {
+			def, err := s.lookup("attribute", ref)
+			if err != nil {
+				return err
+			}
+			decl = def
+		}
+
+		a := &attrDecl{
+			name:     decl.SelectAttr("name"),
+			required: n.SelectAttr("use") == "required",
+		}
+		if t := decl.SelectAttr("type"); t != "" {
+			st, err := s.simpleRef(decl, t)
+			if err != nil {
+				return err
+			}
+			a.typ = st
+		}
+		for _, c := range xsdChildren(decl) {
+			if c.Data == "simpleType" {
+				st, err := s.simpleTypeOf(c)
+				if err != nil {
+					return err
+				}
+				a.typ = st
+			}
+		}
+		for _, src := range []*xmlquery.Node{decl, n} {
+			for _, attr := range src.Attr {
+				if attr.Name.Local == "fixed" {
+					fixed := attr.Value
+					a.fixed = &fixed
+				}
+			}
+		}
+
+		attrs := t.attrs[:0:0]
+		for _, other := range t.attrs {
+			if other.name != a.name {
+				attrs = append(attrs, other)
+			}
+		}
+		if n.SelectAttr("use") != "prohibited" {
+			attrs = append(attrs, a)
+		}
+		t.attrs = attrs
+	}
+
+	return nil
+}
+
+func (s *Schema) particle(n *xmlquery.Node) (*particle, error) {
+	p := &particle{kind: n.Data, min: 1, max: 1}
+	if v := n.SelectAttr("minOccurs"); v != "" {
+		min, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("minOccurs %q: %w", v, err)
+		}
+		p.min = min
+	}
+	if v := n.SelectAttr("maxOccurs"); v == "unbounded" {
+		p.max = -1
+	} else if v != "" {
+		max, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("maxOccurs %q: %w", v, err)
+		}
+		p.max = max
+	}
+
+	switch n.Data {
+	case "element":
+		d, err := s.elementDecl(n)
+		if err != nil {
+			return nil, err
+		}
+		p.elem = d
+
+	case "any":
+
+	case "sequence", "choice", "all":
+		for _, c := range xsdChildren(n) {
+			item, err := s.particle(c)
+			if err != nil {
+				return nil, err
+			}
+			if n.Data == "all" && item.kind != "element" {
+				return nil, fmt.Errorf("all: unexpected %s (only elements are allowed)", item.kind)
+			}
+			p.items = append(p.items, item)
+		}
+
+	case "group":
+		def, err := s.lookup("group", n.SelectAttr("ref"))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range xsdChildren(def) {
+			inner, err := s.particle(c)
+			if err != nil {
+				return nil, err
+			}
+			p.kind = inner.kind
+			p.items = inner.items
+		}
+
+	default:
+		return nil, fmt.Errorf("unexpected %s", n.Data)
+	}
+
+	return p, nil
+}
+
+func newSimpleType() *simpleType {
+	return &simpleType{length: -1, minLength: -1, maxLength: -1}
+}
+
+func (s *Schema) simpleTypeOf(n *xmlquery.Node) (*simpleType, error) {
+	if st, found := s.simples[n]; found {
+		return st, nil
+	}
+	st := newSimpleType()
+	s.simples[n] = st
+
+	for _, c := range xsdChildren(n) {
+		switch c.Data {
+		case "restriction":
+			if base := c.SelectAttr("base"); base != "" {
+				bst, err := s.simpleRef(c, base)
+				if err != nil {
+					return nil, err
+				}
+				st.base = bst
+			}
+			if err := s.facets(st, c); err != nil {
+				return nil, err
+			}
+
+		case "list":
+			if item := c.SelectAttr("itemType"); item != "" {
+				ist, err := s.simpleRef(c, item)
+				if err != nil {
+					return nil, err
+				}
+				st.list = ist
+			}
+			for _, ic := range xsdChildren(c) {
+				ist, err := s.simpleTypeOf(ic)
+				if err != nil {
+					return nil, err
+				}
+				st.list = ist
+			}
+
+		case "union":
+			for _, m := range strings.Fields(c.SelectAttr("memberTypes")) {
+				mst, err := s.simpleRef(c, m)
+				if err != nil {
+					return nil, err
+				}
+				st.union = append(st.union, mst)
+			}
+			for _, mc := range xsdChildren(c) {
+				mst, err := s.simpleTypeOf(mc)
+				if err != nil {
+					return nil, err
+				}
+				st.union = append(st.union, mst)
+			}
+		}
+	}
+
+	return st, nil
+}
+
+func (s *Schema) facets(st *simpleType, n *xmlquery.Node) error {
+	for _, f := range xsdChildren(n) {
+		value := f.SelectAttr("value")
+
+		var err error
+		switch f.Data {
+		case "simpleType":
+			st.base, err = s.simpleTypeOf(f)
+		case "enumeration":
+			st.enums = append(st.enums, value)
+		case "pattern":
+			var expr string
+			if expr, err = translateXSDRegexp(value); err == nil {
+				var re *regexp.Regexp
+				re, err = regexp.Compile(`^(?:` + expr + `)$`)
+				st.patterns = append(st.patterns, xsdPattern{re: re, src: value})
+			}
+		case "length":
+			st.length, err = strconv.Atoi(value)
+		case "minLength":
+			st.minLength, err = strconv.Atoi(value)
+		case "maxLength":
+			st.maxLength, err = strconv.Atoi(value)
+		case "minInclusive":
+			st.minInc = value
+		case "maxInclusive":
+			st.maxInc = value
+		case "minExclusive":
+			st.minExc = value
+		case "maxExclusive":
+			st.maxExc = value
+		}
+		if err != nil {
+			return fmt.Errorf("%s %q: %w", f.Data, value, err)
+		}
+	}
+	return nil
+}
+
+// translateXSDRegexp translates multi-character escapes of XSD regular expressions not in Go.
+// Other escapes (including `\\`) are kept as they are.
+func translateXSDRegexp(re string) (string, error) {
+	classes := map[rune]string{
+		'i': `\p{L}_:`,
+		'c': `\p{L}\p{N}._:\-`,
+	}
+
+	var b strings.Builder
+	inClass := false
+	runes := []rune(re)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			e := runes[i]
+			switch e {
+			case 'i', 'c':
+				if inClass {
+					b.WriteString(classes[e])
+				} else {
+					b.WriteString("[" + classes[e] + "]")
+				}
+			case 'I', 'C':
+				if inClass {
+					return "", fmt.Errorf(`\%c in a character class is not supported`, e)
+				}
+				b.WriteString("[^" + classes[unicode.ToLower(e)] + "]")
+			default:
+				b.WriteRune(r)
+				b.WriteRune(e)
+			}
+
+		case r == '-' && inClass && i+1 < len(runes) && runes[i+1] == '[':
+			// RE2 has no subtraction, and would take [a-z-[aeiou]] as another class
+			return "", errors.New("character class subtraction is not supported")
+
+		case r == '[' && !inClass:
+			inClass = true
+			b.WriteRune(r)
+		case r == ']' && inClass:
+			inClass = false
+			b.WriteRune(r)
+
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+func splitQName(qname string) (prefix, local string) {
+	if prefix, local, found := strings.Cut(qname, ":"); found {
+		return prefix, local
+	}
+	return "", qname
+}
+
+// lookupNamespace returns the namespace URI of prefix in the scope of n.
+func lookupNamespace(n *xmlquery.Node, prefix string) string {
+	for e := n; e != nil; e = e.Parent {
+		for _, attr := range e.Attr {
+			if prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns" ||
+				prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix {
+				return attr.Value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	reDecimal   = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	reInteger   = regexp.MustCompile(`^[+-]?\d+$`)
+	reDate      = regexp.MustCompile(`^-?\d{4,}-\d{2}-\d{2}(Z|[+-]\d{2}:\d{2})?$`)
+	reDateTime  = regexp.MustCompile(`^-?\d{4,}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`)
+	reTime      = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`)
+	reDuration  = regexp.MustCompile(`^-?P(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+	reGYear     = regexp.MustCompile(`^-?\d{4,}(Z|[+-]\d{2}:\d{2})?$`)
+	reGYearMon  = regexp.MustCompile(`^-?\d{4,}-\d{2}(Z|[+-]\d{2}:\d{2})?$`)
+	reName      = regexp.MustCompile(`^[\p{L}_:][\p{L}\p{N}._:\-]*$`)
+	reNCName    = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}._\-]*$`)
+	reQName     = regexp.MustCompile(`^([\p{L}_][\p{L}\p{N}._\-]*:)?[\p{L}_][\p{L}\p{N}._\-]*$`)
+	reNMTOKEN   = regexp.MustCompile(`^[\p{L}\p{N}._:\-]+$`)
+	reLanguage  = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+	reHexBinary = regexp.MustCompile(`^([0-9a-fA-F]{2})*$`)
+)
+
+// integer types with their ranges (nil for unbounded), compared exactly beyond float64
+var xsdIntegers = map[string][2]*big.Int{
+	"integer":            {nil, nil},
+	"nonNegativeInteger": {big.NewInt(0), nil},
+	"positiveInteger":    {big.NewInt(1), nil},
+	"nonPositiveInteger": {nil, big.NewInt(0)},
+	"negativeInteger":    {nil, big.NewInt(-1)},
+	"long":               {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	"int":                {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	"short":              {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	"byte":               {big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)},
+	"unsignedLong":       {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+	"unsignedInt":        {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	"unsignedShort":      {big.NewInt(0), big.NewInt(math.MaxUint16)},
+	"unsignedByte":       {big.NewInt(0), big.NewInt(math.MaxUint8)},
+}
+
+// xsdBuiltins validates values of built-in types other than integers.
+var xsdBuiltins = map[string]func(string) bool{
+	"anySimpleType":    func(string) bool { return true },
+	"string":           func(string) bool { return true },
+	"normalizedString": func(v string) bool { return !strings.ContainsAny(v, "\t\n\r") },
+	"token":            func(v string) bool { return v == collapseSpace(v) },
+	"anyURI":           func(string) bool { return true },
+	"boolean": func(v string) bool {
+		return v == "true" || v == "false" || v == "1" || v == "0"
+	},
+	"decimal":  reDecimal.MatchString,
+	"float":    isXSDFloat,
+	"double":   isXSDFloat,
+	"date":     reDate.MatchString,
+	"dateTime": reDateTime.MatchString,
+	"time":     reTime.MatchString,
+	"duration": func(v string) bool {
+		return reDuration.MatchString(v) && !strings.HasSuffix(v, "P") && !strings.HasSuffix(v, "T")
+	},
+	"gYear":      reGYear.MatchString,
+	"gYearMonth": reGYearMon.MatchString,
+	"Name":       reName.MatchString,
+	"NCName":     reNCName.MatchString,
+	"ID":         reNCName.MatchString,
+	"IDREF":      reNCName.MatchString,
+	"ENTITY":     reNCName.MatchString,
+	"QName":      reQName.MatchString,
+	"NOTATION":   reQName.MatchString,
+	"NMTOKEN":    reNMTOKEN.MatchString,
+	"language":   reLanguage.MatchString,
+	"hexBinary":  reHexBinary.MatchString,
+	"base64Binary": func(v string) bool {
+		_, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+		return err == nil
+	},
+	"IDREFS":   func(v string) bool { return isListOf(v, reNCName.MatchString) },
+	"ENTITIES": func(v string) bool { return isListOf(v, reNCName.MatchString) },
+	"NMTOKENS": func(v string) bool { return isListOf(v, reNMTOKEN.MatchString) },
+}
+
+func builtinType(name string) *simpleType {
+	if _, found := xsdBuiltins[name]; !found {
+		if _, found := xsdIntegers[name]; !found {
+			return nil
+		}
+	}
+
+	st := newSimpleType()
+	st.builtin = name
+	return st
+}
+
+func isXSDFloat(v string) bool {
+	switch v {
+	case "INF", "-INF", "NaN":
+		return true
+	}
+	if strings.ContainsAny(v, "xXpP_") || strings.EqualFold(v, "inf") || strings.EqualFold(v, "infinity") {
+		return false
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil
+}
+
+func isListOf(v string, valid func(string) bool) bool {
+	items := strings.Fields(v)
+	for _, item := range items {
+		if !valid(item) {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+func collapseSpace(v string) string {
+	return strings.Join(strings.Fields(v), " ")
+}
+
+// primitive returns the built-in type that st is derived from.
+func (st *simpleType) primitive() string {
+	for t := st; t != nil; t = t.base {
+		if t.builtin != "" {
+			return t.builtin
+		}
+		if t.list != nil || t.union != nil {
+			return ""
+		}
+	}
+	return "anySimpleType"
+}
+
+func (st *simpleType) isList() bool {
+	for t := st; t != nil; t = t.base {
+		switch {
+		case t.list != nil:
+			return true
+		case t.builtin == "IDREFS", t.builtin == "ENTITIES", t.builtin == "NMTOKENS":
+			return true
+		}
+	}
+	return false
+}
+
+// normalize applies the whitespace facet of st to value.
+func (st *simpleType) normalize(value string) string {
+	switch st.primitive() {
+	case "string", "anySimpleType":
+		return value
+	case "normalizedString":
+		return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(value)
+	}
+	return collapseSpace(value)
+}
+
+func (st *simpleType) validate(value string) error {
+	value = st.normalize(value)
+
+	switch {
+	case st.builtin != "":
+		if r, found := xsdIntegers[st.builtin]; found {
+			if !reInteger.MatchString(value) {
+				return fmt.Errorf("%q is not a valid %s", value, st.builtin)
+			}
+			i, _ := new(big.Int).SetString(value, 10)
+			if r[0] != nil && i.Cmp(r[0]) < 0 || r[1] != nil && i.Cmp(r[1]) > 0 {
+				return fmt.Errorf("%q is out of range of %s", value, st.builtin)
+			}
+			return nil
+		}
+		if !xsdBuiltins[st.builtin](value) {
+			return fmt.Errorf("%q is not a valid %s", value, st.builtin)
+		}
+		return nil
+
+	case st.list != nil:
+		for _, item := range strings.Fields(value) {
+			if err := st.list.validate(item); err != nil {
+				return err
+			}
+		}
+
+	case st.union != nil:
+		valid := false
+		for _, m := range st.union {
+			if m.validate(value) == nil {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("%q is not valid for any member type", value)
+		}
+
+	case st.base != nil:
+		if err := st.base.validate(value); err != nil {
+			return err
+		}
+	}
+
+	return st.validateFacets(value)
+}
+
+func (st *simpleType) validateFacets(value string) error {
+	if len(st.enums) > 0 {
+		found := false
+		for _, e := range st.enums {
+			if st.normalize(e) == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q is not in the enumeration %q", value, st.enums)
+		}
+	}
+
+	for _, p := range st.patterns {
+		if !p.re.MatchString(value) {
+			return fmt.Errorf("%q does not match the pattern %s", value, p.src)
+		}
+	}
+
+	length := utf8.RuneCountInString(value)
+	if st.isList() {
+		length = len(strings.Fields(value))
+	}
+	if st.length >= 0 && length != st.length {
+		return fmt.Errorf("length of %q must be %d", value, st.length)
+	}
+	if st.minLength >= 0 && length < st.minLength {
+		return fmt.Errorf("length of %q must be at least %d", value, st.minLength)
+	}
+	if st.maxLength >= 0 && length > st.maxLength {
+		return fmt.Errorf("length of %q must be at most %d", value, st.maxLength)
+	}
+
+	for _, r := range []struct {
+		bound string
+		op    string
+		ok    func(c int) bool
+	}{
+		{st.minInc, ">=", func(c int) bool { return c >= 0 }},
+		{st.maxInc, "<=", func(c int) bool { return c <= 0 }},
+		{st.minExc, ">", func(c int) bool { return c > 0 }},
+		{st.maxExc, "<", func(c int) bool { return c < 0 }},
+	} {
+		if r.bound != "" && !r.ok(compareXSDValues(value, r.bound)) {
+			return fmt.Errorf("%q must be %s %s", value, r.op, r.bound)
+		}
+	}
+
+	return nil
+}
+
+// compareXSDValues compares a and b as numbers if possible, or as strings (e.g. dates).
+// Decimals are compared exactly, and floats (with exponents, INF, ...) in float64.
+func compareXSDValues(a, b string) int {
+	if reDecimal.MatchString(a) && reDecimal.MatchString(b) {
+		ra, _ := new(big.Rat).SetString(a)
+		rb, _ := new(big.Rat).SetString(b)
+		return ra.Cmp(rb)
+	}
+
+	fa, erra := strconv.ParseFloat(a, 64)
+	fb, errb := strconv.ParseFloat(b, 64)
+	if erra == nil && errb == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"gopkg.in/yaml.v3"
+)
+
+type toYAMLCmd struct {
+	_ struct{} `help:"eksemel to-yaml hoge.xml > hoge.yaml"`
+
+	ForceArray []string `cli:"force-array=XPATH" type:"List" help:"make matched elements arrays even if single (repeatable)"`
+	Namespaces []string `cli:"ns=BINDING" type:"List" help:"bind a namespace prefix=uri for XPath (repeatable)"`
+
+	Indent int `cli:"indent=NUMBER" default:"2"`
+
+	textOutput
+}
+
+type fromYAMLCmd struct {
+	_ struct{} `help:"eksemel from-yaml hoge.yaml > hoge.xml"`
+
+	common
+}
+
+// ToYAML writes input as YAML, in the mapping of ToJSON.
+// XML comments are written as YAML comments before the following node.
+func ToYAML(input io.ReadCloser, output io.Writer, ns map[string]string, forceArray []string, indent int) error {
+	doc, err := parseInput(input, &OutputConfig{})
+	if err != nil {
+		return err
+	}
+
+	c := &yamlConverter{arrays: make(map[*xmlquery.Node]bool)}
+	for _, expr := range forceArray {
+		nodes, err := queryAll(doc, expr, ns)
+		if err != nil {
+			return fmt.Errorf("force-array: %v", err)
+		}
+		for _, n := range nodes {
+			c.arrays[n] = true
+		}
+	}
+
+	nodes := prologNodes(doc)
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		nodes = append(nodes, n)
+	}
+
+	top := &yaml.Node{Kind: yaml.MappingNode}
+	comments := c.members(top, nodes, false)
+
+	ydoc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{top}, FootComment: comments}
+
+	enc := yaml.NewEncoder(output)
+	enc.SetIndent(max(indent, 1))
+	if err := enc.Encode(ydoc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type yamlConverter struct {
+	arrays map[*xmlquery.Node]bool
+}
+
+func yamlString(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// yamlComment converts an XML comment into lines of a YAML comment.
+func yamlComment(data string) string {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	for i, line := range lines {
+		lines[i] = "# " + strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *yamlConverter) value(n *xmlquery.Node) *yaml.Node {
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	for _, attr := range n.Attr {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		m.Content = append(m.Content, yamlString("@"+name), yamlString(attr.Value))
+	}
+
+	var children []*xmlquery.Node
+	hasElem := false
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, child)
+		hasElem = hasElem || child.Type == xmlquery.ElementNode
+	}
+
+	comments := c.members(m, children, hasElem)
+
+	switch {
+	case len(m.Content) == 0:
+		v := yamlString("")
+		v.LineComment = strings.ReplaceAll(comments, "\n", " ")
+		return v
+	case len(m.Content) == 2 && m.Content[0].Value == "#text" && m.Content[0].HeadComment == "" && comments == "":
+		return m.Content[1]
+	}
+	return m
+}
+
+// members appends keys and values for nodes to m.
+// A comment goes to the following key or array item, and comments after them are returned.
+func (c *yamlConverter) members(m *yaml.Node, nodes []*xmlquery.Node, trim bool) string {
+	index := make(map[string]int) // of values in m.Content
+
+	var comments []string
+	var text strings.Builder
+	for _, n := range nodes {
+		switch n.Type {
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if isWhitespaceText(n) {
+				continue
+			}
+			if _, found := index["#text"]; !found {
+				key := yamlString("#text")
+				key.HeadComment = strings.Join(comments, "\n")
+				comments = nil
+				m.Content = append(m.Content, key, yamlString(""))
+				index["#text"] = len(m.Content) - 1
+			}
+			text.WriteString(n.Data)
+
+		case xmlquery.CommentNode:
+			comments = append(comments, yamlComment(n.Data))
+
+		case xmlquery.ElementNode:
+			name := nodeName(n)
+			v := c.value(n)
+
+			i, found := index[name]
+			if !found {
+				key := yamlString(name)
+				key.HeadComment = strings.Join(comments, "\n")
+				comments = nil
+				if c.arrays[n] {
+					v = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{v}}
+				}
+				m.Content = append(m.Content, key, v)
+				index[name] = len(m.Content) - 1
+				continue
+			}
+
+			seq := m.Content[i]
+			if seq.Kind != yaml.SequenceNode {
+				seq = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{seq}}
+				m.Content[i] = seq
+			}
+			v.HeadComment = strings.Join(comments, "\n")
+			comments = nil
+			seq.Content = append(seq.Content, v)
+		}
+	}
+
+	if i, found := index["#text"]; found {
+		if trim {
+			m.Content[i].Value = strings.TrimSpace(text.String())
+		} else {
+			m.Content[i].Value = text.String()
+		}
+	}
+
+	rest := strings.Join(comments, "\n")
+	if rest != "" && len(m.Content) > 0 {
+		m.Content[len(m.Content)-2].FootComment = rest
+		return ""
+	}
+	return rest
+}
+
+// FromYAML writes input YAML as XML, in the mapping of ToJSON.
+// Each line of YAML comments becomes an XML comment.
+func FromYAML(input io.ReadCloser, output io.Writer, config OutputConfig) error {
+	var ydoc yaml.Node
+	err := yaml.NewDecoder(input).Decode(&ydoc)
+	input.Close()
+	if err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+
+	if ydoc.Kind != yaml.DocumentNode || len(ydoc.Content) == 0 || ydoc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("yaml: the top level must be a mapping")
+	}
+
+	v, err := yamlValue(ydoc.Content[0])
+	if err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+	top := appendYAMLComments(v.(jsonObject), ydoc.HeadComment)
+	top = appendYAMLComments(top, ydoc.FootComment)
+
+	doc, err := buildDocument(top)
+	if err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+
+	OutputXML(output, doc, config)
+	return nil
+}
+
+// appendYAMLComments appends "#comment" members for YAML comments to obj.
+func appendYAMLComments(obj jsonObject, comments ...string) jsonObject {
+	var values []any
+	for _, c := range comments {
+		for _, line := range strings.Split(c, "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "#") {
+				continue
+			}
+			values = append(values, " "+strings.TrimSpace(line[1:])+" ")
+		}
+	}
+	if len(values) == 0 {
+		return obj
+	}
+	return append(obj, jsonMember{Key: "#comment", Value: values})
+}
+
+// yamlValue converts n into a value of FromJSON, with comments as "#comment" members.
+func yamlValue(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return nil, nil
+		}
+		return n.Value, nil
+
+	case yaml.SequenceNode:
+		arr := []any{}
+		for _, item := range n.Content {
+			v, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+
+	case yaml.MappingNode:
+		obj := jsonObject{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: a key must be a scalar", k.Line)
+			}
+			obj = appendYAMLComments(obj, k.HeadComment, v.HeadComment)
+
+			if v.Kind != yaml.SequenceNode {
+				value, err := yamlValue(v)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, jsonMember{Key: k.Value, Value: value})
+				obj = appendYAMLComments(obj, k.LineComment, v.LineComment, k.FootComment, v.FootComment)
+				continue
+			}
+
+			// split the array at comments of its items
+			var items []any
+			flush := func() {
+				if len(items) > 0 {
+					obj = append(obj, jsonMember{Key: k.Value, Value: items})
+					items = nil
+				}
+			}
+			for _, item := range v.Content {
+				if item.HeadComment != "" {
+					flush()
+					obj = appendYAMLComments(obj, item.HeadComment)
+				}
+				value, err := yamlValue(item)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+				if item.LineComment != "" || item.FootComment != "" {
+					flush()
+					obj = appendYAMLComments(obj, item.LineComment, item.FootComment)
+				}
+			}
+			flush()
+			obj = appendYAMLComments(obj, k.LineComment, v.LineComment, k.FootComment, v.FootComment)
+		}
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("line %d: unsupported node", n.Line)
+}
+
+func (c toYAMLCmd) Run(args []string) error {
+	ns, err := ParseNamespaces(c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	input, _, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(func(w io.Writer) error {
+		return ToYAML(input, w, ns, c.ForceArray, c.Indent)
+	})
+}
+
+func (c fromYAMLCmd) Before() error {
+	return c.common.checkConverted()
+}
+
+func (c fromYAMLCmd) Run(args []string) error {
+	input, filename, err := openInput(args)
+	if err != nil {
+		return err
+	}
+
+	return c.write(filename, func(w io.Writer) error {
+		return FromYAML(input, w, c.outputConfig(filename))
+	})
+}
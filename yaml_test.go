@@ -0,0 +1,122 @@
+package main_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	main "github.com/shu-go/eksemel"
+	"github.com/shu-go/gotwant"
+)
+
+func TestToYAML(t *testing.T) {
+	data := []struct {
+		input      string
+		forceArray []string
+		out        string
+	}{
+		{
+			input: xmlpi + `<root id="1"><a>text</a><b/><a>80</a></root>`,
+			out: `root:
+  '@id': "1"
+  a:
+    - text
+    - "80"
+  b: ""
+`,
+		},
+		{
+			input: `<!-- header --><root><!-- first --><item>1</item><!-- second --><item>2</item><p>mixed <b>content</b></p><!-- last --></root>`,
+			out: `# header
+root:
+  # first
+  item:
+    - "1"
+    # second
+    - "2"
+  p:
+    '#text': mixed
+    b: content
+  # last
+`,
+		},
+		{
+			input:      xmlpi + `<root><item>1</item></root>`,
+			forceArray: []string{"//item"},
+			out: `root:
+  item:
+    - "1"
+`,
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.ToYAML(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, nil, d.forceArray, 2)
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	data := []struct {
+		input string
+		err   string
+		out   string
+	}{
+		{
+			input: `# header
+root:
+  "@id": 1
+  # first
+  item:
+    - 1
+    - true # second
+    - ~
+  text: |
+    two lines
+    of text
+`,
+			out: `<?xml version="1.0"?><!-- header --><root id="1"><!-- first --><item>1</item><item>true</item><!-- second --><item/><text>two lines
+of text</text></root>`,
+		},
+		{
+			input: `- a`,
+			err:   `yaml: the top level must be a mapping`,
+		},
+		{
+			input: `{a: 1, b: 2}`,
+			err:   `yaml: a single root element is required`,
+		},
+	}
+
+	for i, d := range data {
+		out := &bytes.Buffer{}
+		err := main.FromYAML(main.NewFakeCloseReader(bytes.NewBufferString(d.input)), out, main.OutputConfig{EmptyElement: true})
+
+		seq := strconv.Itoa(i+1) + "/" + strconv.Itoa(len(data))
+
+		if d.err != "" {
+			gotwant.Test(t, err.Error(), d.err, gotwant.Desc(seq))
+			continue
+		}
+		gotwant.TestError(t, err, nil, gotwant.Desc(seq))
+		gotwant.Test(t, out.String(), d.out, gotwant.Desc(seq))
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	src := `<?xml version="1.0"?><!-- servers --><config v="1"><!-- primary --><server name="a"><port>80</port></server><!-- backup --><server name="b"/><debug/><!-- end --></config>`
+
+	y := &bytes.Buffer{}
+	err := main.ToYAML(main.NewFakeCloseReader(bytes.NewBufferString(src)), y, nil, nil, 2)
+	gotwant.TestError(t, err, nil)
+
+	out := &bytes.Buffer{}
+	err = main.FromYAML(main.NewFakeCloseReader(y), out, main.OutputConfig{EmptyElement: true})
+	gotwant.TestError(t, err, nil)
+	gotwant.Test(t, out.String(), src)
+}